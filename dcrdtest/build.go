@@ -0,0 +1,400 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// dcrdModulePath is the path of the dcrd module, whose main package is dcrd.
+const dcrdModulePath = "github.com/decred/dcrd"
+
+var (
+	// dcrdBuildFlags are the flags passed to the go tool when building
+	// dcrd. They are set with SetDcrdBuildFlags.
+	dcrdBuildFlags []string
+
+	// goBinary is the go tool used to build dcrd, set with SetGoBinary.
+	// When empty, the go tool found in PATH is used. goVersion is the
+	// toolchain version it reports, which is looked up on first use.
+	goBinary  string
+	goVersion string
+
+	buildMtx sync.Mutex
+)
+
+// SetGoBinary sets the go tool used by SetDcrdVersion and SetDcrdSourceDir to
+// build dcrd, instead of the go tool found in PATH. This allows building dcrd
+// with a specific toolchain. An empty path restores the go tool in PATH.
+//
+// The binary is checked by running `go version` on it and executables built
+// with different toolchains are cached separately.
+func SetGoBinary(ctx context.Context, path string) error {
+	bin := path
+	if bin == "" {
+		bin = "go"
+	}
+	version, err := goToolVersion(ctx, bin)
+	if err != nil {
+		return err
+	}
+	buildMtx.Lock()
+	goBinary = path
+	goVersion = version
+	buildMtx.Unlock()
+	return nil
+}
+
+// goToolVersion returns the toolchain version (for example, "go1.21.3")
+// reported by `go version` for the passed go binary.
+func goToolVersion(ctx context.Context, bin string) (string, error) {
+	out, err := exec.CommandContext(ctx, bin, "version").Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to run %s version: %w", bin, err)
+	}
+	// The output has the form "go version go1.21.3 linux/amd64".
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 || fields[0] != "go" || fields[1] != "version" ||
+		!strings.HasPrefix(fields[2], "go") {
+
+		return "", fmt.Errorf("%s is not a go tool: unexpected version "+
+			"output %q", bin, bytes.TrimSpace(out))
+	}
+	return fields[2], nil
+}
+
+// goTool returns the go binary used to build dcrd along with its toolchain
+// version.
+func goTool(ctx context.Context) (string, string, error) {
+	buildMtx.Lock()
+	defer buildMtx.Unlock()
+	bin := goBinary
+	if bin == "" {
+		bin = "go"
+	}
+	if goVersion == "" {
+		version, err := goToolVersion(ctx, bin)
+		if err != nil {
+			return "", "", err
+		}
+		goVersion = version
+	}
+	return bin, goVersion, nil
+}
+
+// SetDcrdBuildFlags sets the flags passed to the go tool when SetDcrdVersion
+// and SetDcrdSourceDir build dcrd, such as "-race" to run the nodes with the
+// race detector or "-tags=..." to select build tags. Calling it without flags
+// restores the default build.
+//
+// Executables built with different flags are cached separately, so race and
+// non-race builds of the same dcrd are never mixed.
+func SetDcrdBuildFlags(flags ...string) error {
+	for _, flag := range flags {
+		if !strings.HasPrefix(flag, "-") {
+			return fmt.Errorf("invalid build flag %q", flag)
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(flag, "-"), "=")
+		if name == "o" {
+			return fmt.Errorf("build flag %q may not be set, since "+
+				"the output is managed by dcrdtest", flag)
+		}
+	}
+	buildMtx.Lock()
+	dcrdBuildFlags = append([]string(nil), flags...)
+	buildMtx.Unlock()
+	return nil
+}
+
+// buildFlags returns a copy of the flags set with SetDcrdBuildFlags.
+func buildFlags() []string {
+	buildMtx.Lock()
+	defer buildMtx.Unlock()
+	return append([]string(nil), dcrdBuildFlags...)
+}
+
+// dcrdBinDir returns the dir under the OS temp dir where the dcrd executable
+// identified by name (such as its version) and built by the passed toolchain
+// version with the passed flags is cached. Builds with different toolchains or
+// flags are cached in different dirs.
+func dcrdBinDir(name, goVersion string, flags []string) string {
+	name += "-" + goVersion
+	if len(flags) > 0 {
+		sum := sha256.Sum256([]byte(strings.Join(flags, "\x00")))
+		name += "-" + hex.EncodeToString(sum[:4])
+	}
+	return filepath.Join(buildCacheDir(), name)
+}
+
+// buildCacheDir returns the dir under the OS temp dir that holds the dirs of
+// the dcrd executables built by SetDcrdVersion and SetDcrdSourceDir.
+func buildCacheDir() string {
+	return filepath.Join(os.TempDir(), "dcrdtest")
+}
+
+// dcrdBuild is a build of dcrd in progress, which other callers building the
+// same executable wait on.
+type dcrdBuild struct {
+	done chan struct{}
+	err  error
+}
+
+var (
+	// dcrdBuilds are the builds of dcrd in progress, keyed by the dir of
+	// the built executable.
+	dcrdBuilds    = make(map[string]*dcrdBuild)
+	dcrdBuildsMtx sync.Mutex
+)
+
+// buildOnce runs build, which builds the dcrd executable in binDir, unless a
+// build of the same executable is already in progress, in which case it waits
+// for that one to finish and returns its result instead. Thus, concurrent
+// callers never run the go tool on the same dir at the same time.
+func buildOnce(ctx context.Context, binDir string, build func() error) error {
+	dcrdBuildsMtx.Lock()
+	if b, ok := dcrdBuilds[binDir]; ok {
+		dcrdBuildsMtx.Unlock()
+		select {
+		case <-b.done:
+			return b.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	b := &dcrdBuild{done: make(chan struct{})}
+	dcrdBuilds[binDir] = b
+	dcrdBuildsMtx.Unlock()
+
+	b.err = build()
+
+	dcrdBuildsMtx.Lock()
+	delete(dcrdBuilds, binDir)
+	dcrdBuildsMtx.Unlock()
+	close(b.done)
+	return b.err
+}
+
+// CleanBuildCache removes the dcrd executables built by SetDcrdVersion and
+// SetDcrdSourceDir, which are otherwise kept under the OS temp dir across
+// runs so that later builds are fast. It is meant to be called once all tests
+// are done (for example, in TestMain after TearDownAll), since the dcrd
+// executable set by those functions no longer exists afterwards.
+//
+// An error is returned if a build is in progress.
+func CleanBuildCache() error {
+	dcrdBuildsMtx.Lock()
+	defer dcrdBuildsMtx.Unlock()
+	if len(dcrdBuilds) > 0 {
+		return fmt.Errorf("unable to clean the build cache while %d "+
+			"builds of dcrd are in progress", len(dcrdBuilds))
+	}
+	return os.RemoveAll(buildCacheDir())
+}
+
+// dcrdExecutable returns the file name of the dcrd executable for the current
+// OS.
+func dcrdExecutable() string {
+	if runtime.GOOS == "windows" {
+		return "dcrd.exe"
+	}
+	return "dcrd"
+}
+
+// runGo runs the go tool set with SetGoBinary, or the one in PATH by default,
+// with the passed args in dir, or the current dir when empty, adding env to
+// the environment. The output of the go tool is logged line by line as it is
+// produced, so the progress of long builds is visible.
+//
+// The go tool is killed when the context is done, in which case the returned
+// error wraps the error of the context. Otherwise, the returned error includes
+// the output of the go tool verbatim.
+func runGo(ctx context.Context, dir string, env map[string]string, args ...string) error {
+	bin, _, err := goTool(ctx)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = dir
+	cmd.Env = mergeEnv(os.Environ(), env)
+
+	// The output is read through an explicit pipe rather than by exec, so
+	// that waiting for the go tool to be killed does not also wait for the
+	// processes it started, which may keep the pipe open, to finish.
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer pr.Close()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	err = cmd.Start()
+	pw.Close()
+	if err != nil {
+		return fmt.Errorf("unable to run go %s: %w", args[0], err)
+	}
+
+	var output bytes.Buffer
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			logf(nil, "go %s: %s", args[0], scanner.Text())
+			output.Write(scanner.Bytes())
+			output.WriteByte('\n')
+		}
+	}()
+
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		// Unblock the reader in case the pipe is still held open.
+		pr.Close()
+		<-readDone
+		return fmt.Errorf("go %s cancelled: %w", args[0], ctx.Err())
+	}
+	<-readDone
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, output.Bytes())
+	}
+	return nil
+}
+
+// SetDcrdVersion builds the passed version of dcrd (for example, "v1.8.0" or
+// a commit hash) with `go install` and sets it as the package level dcrd
+// executable via SetPathToDCRD. This allows testing against a specific dcrd
+// release regardless of the dcrd found in PATH.
+//
+// The go tool downloads the module into the module cache and the executable
+// is installed into a dir under the OS temp dir named after the version and
+// toolchain, so later builds of the same version are fast. The flags set with
+// SetDcrdBuildFlags are passed to the go tool. Build errors include the output
+// of the go tool verbatim. Concurrent calls that build the same executable
+// share a single build. The built executables are removed by CleanBuildCache.
+//
+// The build is cancelled when the context is done, which allows bounding its
+// duration with a deadline.
+//
+// NOTE: This requires the go tool to be available in PATH, unless set with
+// SetGoBinary, and may require network access to download the module.
+func SetDcrdVersion(ctx context.Context, modVersion string) error {
+	if modVersion == "" || strings.ContainsAny(modVersion, "@/\\ ") {
+		return fmt.Errorf("invalid dcrd module version %q", modVersion)
+	}
+
+	_, version, err := goTool(ctx)
+	if err != nil {
+		return err
+	}
+	flags := buildFlags()
+	binDir := dcrdBinDir("dcrd@"+modVersion, version, flags)
+	err = buildOnce(ctx, binDir, func() error {
+		if err := os.MkdirAll(binDir, 0700); err != nil {
+			return err
+		}
+		env := map[string]string{"GOBIN": binDir}
+		args := append([]string{"install", "-v"}, flags...)
+		args = append(args, dcrdModulePath+"@"+modVersion)
+		return runGo(ctx, "", env, args...)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to build dcrd %s: %w", modVersion, err)
+	}
+
+	path := filepath.Join(binDir, dcrdExecutable())
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("dcrd executable not found after build: %w",
+			err)
+	}
+	SetPathToDCRD(path)
+	return nil
+}
+
+// SetDcrdSourceDir builds dcrd from the passed source dir, such as a working
+// tree with unreleased changes, and sets it as the package level dcrd
+// executable via SetPathToDCRD. The dir must be the root of the dcrd module,
+// which holds the dcrd main package.
+//
+// The executable is built into a dir under the OS temp dir specific to the
+// source dir, so later builds are incremental. The flags set with
+// SetDcrdBuildFlags are passed to the go tool. Build errors include the output
+// of the go tool verbatim, so compilation failures of the source are obvious.
+// Concurrent calls that build the same executable share a single build. The
+// built executables are removed by CleanBuildCache.
+//
+// The build is cancelled when the context is done, which allows bounding its
+// duration with a deadline.
+//
+// NOTE: This requires the go tool to be available in PATH, unless set with
+// SetGoBinary.
+func SetDcrdSourceDir(ctx context.Context, path string) error {
+	srcDir, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if err := checkDcrdSourceDir(srcDir); err != nil {
+		return err
+	}
+
+	_, version, err := goTool(ctx)
+	if err != nil {
+		return err
+	}
+	flags := buildFlags()
+	sum := sha256.Sum256([]byte(srcDir))
+	binDir := dcrdBinDir("src-"+hex.EncodeToString(sum[:8]), version, flags)
+	binPath := filepath.Join(binDir, dcrdExecutable())
+	err = buildOnce(ctx, binDir, func() error {
+		if err := os.MkdirAll(binDir, 0700); err != nil {
+			return err
+		}
+		args := append([]string{"build", "-v"}, flags...)
+		args = append(args, "-o", binPath, ".")
+		return runGo(ctx, srcDir, nil, args...)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to build dcrd from %s: %w", srcDir, err)
+	}
+	SetPathToDCRD(binPath)
+	return nil
+}
+
+// checkDcrdSourceDir returns an error if the passed dir is not the root of the
+// dcrd module with its main package.
+func checkDcrdSourceDir(dir string) error {
+	goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return fmt.Errorf("%s is not a dcrd source dir: %w", dir, err)
+	}
+	module := ""
+	for _, line := range strings.Split(string(goMod), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "module" {
+			module = fields[1]
+			break
+		}
+	}
+	if module != dcrdModulePath {
+		return fmt.Errorf("%s is not a dcrd source dir: module is %q, "+
+			"want %q", dir, module, dcrdModulePath)
+	}
+
+	mainFile := filepath.Join(dir, "dcrd.go")
+	if _, err := os.Stat(mainFile); err != nil {
+		return fmt.Errorf("%s does not contain the dcrd main package: %w",
+			dir, err)
+	}
+	return nil
+}
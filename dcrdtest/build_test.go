@@ -0,0 +1,253 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSetDcrdVersionInvalid ensures invalid versions are rejected before
+// invoking the go tool.
+func TestSetDcrdVersionInvalid(t *testing.T) {
+	for _, version := range []string{"", "v1.8.0 -x", "foo@v1.8.0", "../v1"} {
+		err := SetDcrdVersion(context.Background(), version)
+		if err == nil {
+			t.Fatalf("%q: expected an error", version)
+		}
+	}
+}
+
+// TestCheckDcrdSourceDir ensures only the root of the dcrd module is accepted
+// as a dcrd source dir.
+func TestCheckDcrdSourceDir(t *testing.T) {
+	write := func(dir, name, contents string) {
+		t.Helper()
+		err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Missing go.mod.
+	dir := t.TempDir()
+	if err := checkDcrdSourceDir(dir); err == nil {
+		t.Fatal("expected an error without go.mod")
+	}
+
+	// Other module.
+	write(dir, "go.mod", "module github.com/decred/dcrd/wire\n\ngo 1.17\n")
+	if err := checkDcrdSourceDir(dir); err == nil {
+		t.Fatal("expected an error for another module")
+	}
+
+	// Missing main package.
+	write(dir, "go.mod", "module github.com/decred/dcrd\n\ngo 1.17\n")
+	if err := checkDcrdSourceDir(dir); err == nil {
+		t.Fatal("expected an error without the main package")
+	}
+
+	write(dir, "dcrd.go", "package main\n")
+	if err := checkDcrdSourceDir(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestRunGoCancel ensures the go tool is killed once the context is done and
+// that a timeout error is returned, even when the process it started keeps
+// running.
+func TestRunGoCancel(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not found")
+	}
+
+	// The go tool runs a program which outlives the deadline.
+	dir := t.TempDir()
+	prog := "package main\n\nimport \"time\"\n\n" +
+		"func main() { time.Sleep(30 * time.Second) }\n"
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(prog),
+		0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	err := runGo(ctx, dir, nil, "run", "main.go")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Second {
+		t.Fatalf("cancelled go tool returned after %v", elapsed)
+	}
+}
+
+// TestRunGoOutput ensures errors of the go tool include its output.
+func TestRunGoOutput(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not found")
+	}
+
+	err := runGo(context.Background(), "", nil, "version")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = runGo(context.Background(), "", nil, "nosuchcommand")
+	if err == nil || !strings.Contains(err.Error(), "nosuchcommand") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestSetDcrdBuildFlags ensures invalid build flags are rejected and that
+// builds with different flags are cached in different dirs.
+func TestSetDcrdBuildFlags(t *testing.T) {
+	defer SetDcrdBuildFlags()
+
+	for _, flags := range [][]string{{"race"}, {"-o", "dcrd"}, {"-o=dcrd"}} {
+		if err := SetDcrdBuildFlags(flags...); err == nil {
+			t.Fatalf("%q: expected an error", flags)
+		}
+	}
+	want := []string{"-race", "-tags=foo"}
+	if err := SetDcrdBuildFlags(want...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := buildFlags()
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected build flags %q, want %q", got, want)
+	}
+
+	plain := dcrdBinDir("dcrd@v1.8.0", "go1.21.3", nil)
+	race := dcrdBinDir("dcrd@v1.8.0", "go1.21.3", []string{"-race"})
+	tags := dcrdBinDir("dcrd@v1.8.0", "go1.21.3", []string{"-tags=foo"})
+	if plain == race || race == tags || plain == tags {
+		t.Fatalf("build dirs are not distinct: %s, %s, %s", plain, race,
+			tags)
+	}
+	if race != dcrdBinDir("dcrd@v1.8.0", "go1.21.3", []string{"-race"}) {
+		t.Fatal("build dir is not deterministic")
+	}
+	if filepath.Base(plain) != "dcrd@v1.8.0-go1.21.3" {
+		t.Fatalf("unexpected default build dir %s", plain)
+	}
+}
+
+// TestSetGoBinary ensures only go tools are accepted as the go binary and that
+// builds with different toolchains are cached in different dirs.
+func TestSetGoBinary(t *testing.T) {
+	goPath, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not available in PATH")
+	}
+	ctx := context.Background()
+	defer SetGoBinary(ctx, "")
+
+	missing := filepath.Join(t.TempDir(), "nosuchgo")
+	if err := SetGoBinary(ctx, missing); err == nil {
+		t.Fatal("expected an error for a missing go binary")
+	}
+	if runtime.GOOS != "windows" {
+		fake := filepath.Join(t.TempDir(), "fakego")
+		script := "#!/bin/sh\necho not a go tool\n"
+		if err := os.WriteFile(fake, []byte(script), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := SetGoBinary(ctx, fake); err == nil {
+			t.Fatal("expected an error for a binary that is not go")
+		}
+	}
+
+	if err := SetGoBinary(ctx, goPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bin, version, err := goTool(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bin != goPath || !strings.HasPrefix(version, "go") {
+		t.Fatalf("unexpected go tool %s %s", bin, version)
+	}
+
+	if dcrdBinDir("dcrd@v1.8.0", "go1.20.1", nil) ==
+		dcrdBinDir("dcrd@v1.8.0", "go1.21.3", nil) {
+
+		t.Fatal("build dirs of different toolchains are not distinct")
+	}
+}
+
+// TestBuildOnce ensures concurrent builds of the same executable share a
+// single build.
+func TestBuildOnce(t *testing.T) {
+	const binDir = "/path/to/bin"
+	started := make(chan struct{})
+	release := make(chan struct{})
+	errBuild := errors.New("build error")
+	var builds int32
+
+	ctx := context.Background()
+	errs := make(chan error, 2)
+	go func() {
+		errs <- buildOnce(ctx, binDir, func() error {
+			atomic.AddInt32(&builds, 1)
+			close(started)
+			<-release
+			return errBuild
+		})
+	}()
+	<-started
+	go func() {
+		errs <- buildOnce(ctx, binDir, func() error {
+			atomic.AddInt32(&builds, 1)
+			return nil
+		})
+	}()
+
+	// Wait for the second caller to be waiting on the first build.
+	time.Sleep(100 * time.Millisecond)
+	if err := CleanBuildCache(); err == nil {
+		t.Fatal("expected an error cleaning the cache during a build")
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; !errors.Is(err, errBuild) {
+			t.Fatalf("unexpected error %v, want %v", err, errBuild)
+		}
+	}
+	if n := atomic.LoadInt32(&builds); n != 1 {
+		t.Fatalf("unexpected number of builds %d, want 1", n)
+	}
+
+	// A new build runs once the previous one is done.
+	if err := buildOnce(ctx, binDir, func() error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestCleanBuildCache ensures CleanBuildCache removes the dirs of the built
+// executables.
+func TestCleanBuildCache(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("temp dir is not overridden by TMPDIR on windows")
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+
+	binDir := dcrdBinDir("dcrd@v1.8.0", "go1.21.3", nil)
+	if err := os.MkdirAll(binDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := CleanBuildCache(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(buildCacheDir()); !os.IsNotExist(err) {
+		t.Fatalf("build cache dir was not removed: %v", err)
+	}
+}
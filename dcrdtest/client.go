@@ -0,0 +1,77 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil/v4"
+	dcrdtypes "github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+	"github.com/decred/dcrd/rpcclient/v8"
+	"github.com/decred/dcrd/txscript/v4/stdaddr"
+	"github.com/decred/dcrd/wire"
+)
+
+// RPCClient is the set of dcrd RPC methods used by the harness, as provided by
+// its RPC client.
+//
+// Tests which only depend on this interface, obtained through Harness.Client,
+// do not reference the RPC client package used by dcrdtest, so a new major
+// version of it does not affect them as long as the method signatures are
+// kept. The insulation is only partial though: the interface still exposes the
+// result types of the rpc/jsonrpc/types/v4 package, so a new major version of
+// that package does require changes to tests that use those results. Methods
+// not covered by the interface may be called through RawRequest.
+//
+// The connection and the notifications of the client are managed by the
+// harness, thus they are not part of the interface.
+type RPCClient interface {
+	EstimateSmartFee(ctx context.Context, confirmations int64, mode dcrdtypes.EstimateSmartFeeMode) (*dcrdtypes.EstimateSmartFeeResult, error)
+	ExistsAddress(ctx context.Context, address stdaddr.Address) (bool, error)
+	Generate(ctx context.Context, numBlocks uint32) ([]*chainhash.Hash, error)
+	GetBestBlock(ctx context.Context) (*chainhash.Hash, int64, error)
+	GetBestBlockHash(ctx context.Context) (*chainhash.Hash, error)
+	GetBlock(ctx context.Context, blockHash *chainhash.Hash) (*wire.MsgBlock, error)
+	GetBlockChainInfo(ctx context.Context) (*dcrdtypes.GetBlockChainInfoResult, error)
+	GetBlockCount(ctx context.Context) (int64, error)
+	GetBlockHash(ctx context.Context, blockHeight int64) (*chainhash.Hash, error)
+	GetBlockHeader(ctx context.Context, hash *chainhash.Hash) (*wire.BlockHeader, error)
+	GetBlockHeaderVerbose(ctx context.Context, hash *chainhash.Hash) (*dcrdtypes.GetBlockHeaderVerboseResult, error)
+	GetBlockVerbose(ctx context.Context, blockHash *chainhash.Hash, verboseTx bool) (*dcrdtypes.GetBlockVerboseResult, error)
+	GetInfo(ctx context.Context) (*dcrdtypes.InfoChainResult, error)
+	GetNetworkInfo(ctx context.Context) (*dcrdtypes.GetNetworkInfoResult, error)
+	GetPeerInfo(ctx context.Context) ([]dcrdtypes.GetPeerInfoResult, error)
+	GetRawMempool(ctx context.Context, txType dcrdtypes.GetRawMempoolTxTypeCmd) ([]*chainhash.Hash, error)
+	GetRawTransaction(ctx context.Context, txHash *chainhash.Hash) (*dcrutil.Tx, error)
+	GetStakeDifficulty(ctx context.Context) (*dcrdtypes.GetStakeDifficultyResult, error)
+	GetTxOut(ctx context.Context, txHash *chainhash.Hash, index uint32, tree int8, mempool bool) (*dcrdtypes.GetTxOutResult, error)
+	GetWork(ctx context.Context) (*dcrdtypes.GetWorkResult, error)
+	GetWorkSubmit(ctx context.Context, data string) (bool, error)
+	LiveTickets(ctx context.Context) ([]*chainhash.Hash, error)
+	RawRequest(ctx context.Context, method string, params []json.RawMessage) (json.RawMessage, error)
+	RegenTemplate(ctx context.Context) error
+	SendRawTransaction(ctx context.Context, tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error)
+	SetGenerate(ctx context.Context, enable bool, numCPUs int) error
+}
+
+// Ensure the RPC client used by the harness implements RPCClient.
+var _ RPCClient = (*rpcclient.Client)(nil)
+
+// Client returns the RPC client of the harness' node as an RPCClient. It
+// should be preferred over the Node field, which exposes the concrete client
+// and thus its major version.
+//
+// The returned client is replaced when the node is restarted, so it should be
+// obtained again after calling Restart or RegenerateCert. Nil is returned while
+// the harness is not connected to its node, such as before SetUp.
+func (h *Harness) Client() RPCClient {
+	// Avoid returning a non-nil interface holding a nil client.
+	if h.Node == nil {
+		return nil
+	}
+	return h.Node
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/v3"
+)
+
+// Cluster is a set of harnesses whose nodes are connected to each other in a
+// full mesh.
+type Cluster struct {
+	// Nodes are the harnesses of the cluster, in the order of their
+	// sequential node numbers.
+	Nodes []*Harness
+
+	// startGoroutines is the number of goroutines that were running when
+	// the cluster was created, which TearDown waits to return to.
+	startGoroutines int
+}
+
+// clusterTearDownTimeout is the max time TearDown waits for the goroutines of
+// the cluster to finish after tearing down its harnesses.
+const clusterTearDownTimeout = 10 * time.Second
+
+// NewCluster creates and sets up n harnesses for the passed network, each one
+// created with the passed options, and connects their nodes in a full mesh.
+// All nodes are launched from the package level dcrd executable (see
+// SetPathToDCRD and SetDcrdVersion), so dcrd is built at most once.
+//
+// The harnesses are created in sequence, so their node numbers, which prefix
+// their log lines, are sequential unless other harnesses are concurrently
+// created. This function blocks until every node agrees on the same tip.
+//
+// The returned cluster must be torn down with TearDown, which is also done by
+// NewCluster itself on errors.
+func NewCluster(ctx context.Context, t *testing.T, activeNet *chaincfg.Params, n int, opts ...HarnessOption) (*Cluster, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("cluster must have at least one node "+
+			"(got %d)", n)
+	}
+
+	c := &Cluster{
+		Nodes:           make([]*Harness, 0, n),
+		startGoroutines: runtime.NumGoroutine(),
+	}
+	setUp := func() error {
+		for i := 0; i < n; i++ {
+			h, err := New(t, activeNet, nil, nil, opts...)
+			if err != nil {
+				return err
+			}
+			c.Nodes = append(c.Nodes, h)
+			if err := h.SetUp(ctx, false, 0); err != nil {
+				return fmt.Errorf("unable to set up node %d: %w",
+					i, err)
+			}
+		}
+		for i, from := range c.Nodes {
+			for _, to := range c.Nodes[i+1:] {
+				if err := ConnectNode(ctx, from, to); err != nil {
+					return err
+				}
+			}
+		}
+		return c.WaitForConvergence(ctx)
+	}
+	if err := setUp(); err != nil {
+		if tdErr := c.TearDown(); tdErr != nil {
+			logf(t, "unable to tear down cluster: %v", tdErr)
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// WaitForConvergence blocks until every node of the cluster reports the same
+// best block hash or the passed context is done. See the WaitForConvergence
+// function for details.
+func (c *Cluster) WaitForConvergence(ctx context.Context) error {
+	return WaitForConvergence(ctx, c.Nodes)
+}
+
+// TearDown tears down every harness of the cluster and then asserts no
+// goroutines were leaked, by waiting up to clusterTearDownTimeout for the
+// number of running goroutines to return to the one of when the cluster was
+// created. All harnesses are torn down even if tearing down one of them fails,
+// in which case the first error is returned.
+//
+// NOTE: The leak check counts every goroutine of the process, so goroutines
+// started concurrently by other code (such as parallel tests) that are still
+// running when the cluster is torn down are reported as leaked.
+func (c *Cluster) TearDown() error {
+	var firstErr error
+	for _, h := range c.Nodes {
+		if err := h.TearDown(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		clusterTearDownTimeout)
+	defer cancel()
+	var goroutines int
+	return pollUntil(ctx, func() (bool, error) {
+		goroutines = runtime.NumGoroutine()
+		return goroutines <= c.startGoroutines, nil
+	}, func(err error) error {
+		return fmt.Errorf("leaked goroutines after tearing down the "+
+			"cluster: got %d, want at most %d", goroutines,
+			c.startGoroutines)
+	})
+}
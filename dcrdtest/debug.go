@@ -5,6 +5,7 @@
 package dcrdtest
 
 import (
+	"log"
 	"testing"
 )
 
@@ -21,7 +22,14 @@ func init() {
 	trace = false
 }
 
+// logf logs using the passed test's logger. Harnesses which are not bound to
+// a single test (such as the one returned by SharedHarness) have a nil t, in
+// which case the standard logger is used instead.
 func logf(t *testing.T, format string, args ...interface{}) {
+	if t == nil {
+		log.Printf(format, args...)
+		return
+	}
 	t.Logf(format, args...)
 }
 
@@ -29,12 +37,12 @@ func tracef(t *testing.T, format string, args ...interface{}) {
 	if !trace {
 		return
 	}
-	t.Logf(format, args...)
+	logf(t, format, args...)
 }
 
 func debugf(t *testing.T, format string, args ...interface{}) {
 	if !debug {
 		return
 	}
-	t.Logf(format, args...)
+	logf(t, format, args...)
 }
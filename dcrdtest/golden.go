@@ -0,0 +1,84 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UpdateGoldenFiles causes AssertChainMatches to write the current chain of
+// the node to the golden file instead of comparing against it. Test suites
+// usually bind it to a command line flag in order to regenerate their golden
+// files:
+//
+//	func init() {
+//		flag.BoolVar(&dcrdtest.UpdateGoldenFiles, "update", false,
+//			"update golden files")
+//	}
+var UpdateGoldenFiles bool
+
+// chainLines returns one line in the form "<height> <block hash>" for every
+// block of the main chain of the harness' node, from genesis to the tip.
+func (h *Harness) chainLines(ctx context.Context) ([]string, error) {
+	_, tipHeight, err := h.Node.GetBestBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, tipHeight+1)
+	for height := int64(0); height <= tipHeight; height++ {
+		hash, err := h.Node.GetBlockHash(ctx, height)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, fmt.Sprintf("%d %s", height, hash))
+	}
+	return lines, nil
+}
+
+// AssertChainMatches compares the hashes of all blocks of the main chain of
+// the harness' node against the golden file at goldenPath and returns an
+// error describing the first difference found, if any. This allows tests
+// which build chains deterministically (for example, from fixture blocks) to
+// detect unintended changes in the resulting chain.
+//
+// The golden file has one line per block in the form "<height> <block hash>".
+// When UpdateGoldenFiles is set, the golden file (and any missing parent dirs)
+// is written with the current chain instead.
+func (h *Harness) AssertChainMatches(ctx context.Context, goldenPath string) error {
+	lines, err := h.chainLines(ctx)
+	if err != nil {
+		return err
+	}
+
+	if UpdateGoldenFiles {
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0755); err != nil {
+			return err
+		}
+		data := []byte(strings.Join(lines, "\n") + "\n")
+		return os.WriteFile(goldenPath, data, 0644)
+	}
+
+	data, err := os.ReadFile(goldenPath)
+	if err != nil {
+		return fmt.Errorf("unable to read golden file (set "+
+			"UpdateGoldenFiles to create it): %w", err)
+	}
+	golden := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for i := 0; i < len(lines) && i < len(golden); i++ {
+		if lines[i] != golden[i] {
+			return fmt.Errorf("chain differs from golden file %s: "+
+				"got %q, want %q", goldenPath, lines[i], golden[i])
+		}
+	}
+	if len(lines) != len(golden) {
+		return fmt.Errorf("chain differs from golden file %s: got %d "+
+			"blocks, want %d", goldenPath, len(lines), len(golden))
+	}
+	return nil
+}
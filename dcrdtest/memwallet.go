@@ -113,6 +113,12 @@ type memWallet struct {
 	chainUpdateSignal chan struct{}
 	chainMtx          sync.Mutex
 
+	// quit is closed by Stop to signal the wallet goroutines to exit and
+	// wg tracks the chainSyncer goroutine.
+	quit     chan struct{}
+	quitOnce sync.Once
+	wg       sync.WaitGroup
+
 	net *chaincfg.Params
 
 	t *testing.T
@@ -123,18 +129,22 @@ type memWallet struct {
 }
 
 // newMemWallet creates and returns a fully initialized instance of the
-// memWallet given a particular blockchain's parameters.
-func newMemWallet(t *testing.T, net *chaincfg.Params, harnessID uint32) (*memWallet, error) {
-	// The wallet's final HD seed is: hdSeed || harnessID. This method
-	// ensures that each harness instance uses a deterministic root seed
-	// based on its harness ID.
-	var harnessHDSeed [chainhash.HashSize + 4]byte
-	copy(harnessHDSeed[:], hdSeed[:])
-	binary.BigEndian.PutUint32(harnessHDSeed[:chainhash.HashSize], harnessID)
-
-	hdRoot, err := hdkeychain.NewMaster(harnessHDSeed[:], net)
+// memWallet given a particular blockchain's parameters. When seed is nil, the
+// HD seed of the wallet is derived from the harness ID.
+func newMemWallet(t *testing.T, net *chaincfg.Params, harnessID uint32, seed []byte) (*memWallet, error) {
+	if seed == nil {
+		// The wallet's final HD seed is: hdSeed || harnessID. This
+		// method ensures that each harness instance uses a
+		// deterministic root seed based on its harness ID.
+		var harnessHDSeed [chainhash.HashSize + 4]byte
+		copy(harnessHDSeed[:], hdSeed[:])
+		binary.BigEndian.PutUint32(harnessHDSeed[:chainhash.HashSize], harnessID)
+		seed = harnessHDSeed[:]
+	}
+
+	hdRoot, err := hdkeychain.NewMaster(seed, net)
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 
 	// The first child key from the hd root is reserved as the coinbase
@@ -167,15 +177,24 @@ func newMemWallet(t *testing.T, net *chaincfg.Params, harnessID uint32) (*memWal
 		t:                 t,
 		utxos:             make(map[wire.OutPoint]*utxo),
 		chainUpdateSignal: make(chan struct{}),
+		quit:              make(chan struct{}),
 		reorgJournal:      make(map[int64]*undoEntry),
 	}, nil
 }
 
 // Start launches all goroutines required for the wallet to function properly.
 func (m *memWallet) Start() {
+	m.wg.Add(1)
 	go m.chainSyncer()
 }
 
+// Stop signals all goroutines of the wallet to exit and waits for them to do
+// so. Block updates received afterwards are ignored.
+func (m *memWallet) Stop() {
+	m.quitOnce.Do(func() { close(m.quit) })
+	m.wg.Wait()
+}
+
 // SyncedHeight returns the height the wallet is known to be synced to.
 //
 // This function is safe for concurrent access.
@@ -223,7 +242,10 @@ func (m *memWallet) IngestBlock(header []byte, filteredTxns [][]byte) {
 	// available. We do this in a new goroutine in order to avoid blocking
 	// the main loop of the rpc client.
 	go func() {
-		m.chainUpdateSignal <- struct{}{}
+		select {
+		case m.chainUpdateSignal <- struct{}{}:
+		case <-m.quit:
+		}
 	}()
 }
 
@@ -235,9 +257,17 @@ func (m *memWallet) chainSyncer() {
 	tracef(m.t, "memwallet.chainSyncer")
 	defer tracef(m.t, "memwallet.chainSyncer exit")
 
+	defer m.wg.Done()
+
 	var update *chainUpdate
 
-	for range m.chainUpdateSignal {
+	for {
+		select {
+		case <-m.chainUpdateSignal:
+		case <-m.quit:
+			return
+		}
+
 		// A new update is available, so pop the new chain update from
 		// the front of the update queue.
 		m.chainMtx.Lock()
@@ -280,10 +310,12 @@ func (m *memWallet) evalOutputs(outputs []*wire.TxOut, txHash *chainhash.Hash, i
 		pkScript := output.PkScript
 
 		// Scan all the addresses we currently control to see if the
-		// output is paying to us.
+		// output is paying to us. Only regular payments are considered,
+		// since stake outputs (such as those of tickets) cannot be
+		// spent by regular transactions.
 		for keyIndex, addr := range m.addrs {
-			pkHash := addr.(stdaddr.Hash160er).Hash160()
-			if !bytes.Contains(pkScript, pkHash[:]) {
+			_, addrScript := addr.PaymentScript()
+			if !bytes.Equal(pkScript, addrScript) {
 				continue
 			}
 
@@ -552,6 +584,109 @@ func (m *memWallet) CreateTransaction(ctx context.Context, outputs []*wire.TxOut
 	return tx, nil
 }
 
+// createTicket returns a fully signed ticket purchase transaction paying the
+// passed ticket price, funded by a single mature output of the wallet, while
+// observing the desired fee rate. The passed fee rate should be expressed in
+// atoms-per-byte.
+//
+// The voting rights and the reward commitment of the ticket are assigned to a
+// fresh address of the wallet. The change output pays to another fresh
+// address, but it is not tracked by the wallet, since it is a stake output.
+//
+// This function is safe for concurrent access.
+func (m *memWallet) createTicket(ctx context.Context, ticketPrice, feeRate dcrutil.Amount) (*wire.MsgTx, error) {
+	tracef(m.t, "memwallet.createTicket")
+	defer tracef(m.t, "memwallet.createTicket exit")
+
+	const (
+		// spendSize is the largest number of bytes of a sigScript
+		// which spends a p2pkh output: OP_DATA_73 <sig> OP_DATA_33 <pubkey>
+		spendSize = 1 + 73 + 1 + 33
+
+		// revokeFeeLimit is the max fee allowed for revoking the ticket.
+		revokeFeeLimit = 16777216
+	)
+
+	m.Lock()
+	defer m.Unlock()
+
+	addr, err := m.newAddress(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changeAddr, err := m.newAddress(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stakeAddr, ok := addr.(stdaddr.StakeAddress)
+	if !ok {
+		return nil, fmt.Errorf("address %v cannot be used in tickets", addr)
+	}
+	stakeChangeAddr, ok := changeAddr.(stdaddr.StakeAddress)
+	if !ok {
+		return nil, fmt.Errorf("address %v cannot be used in tickets",
+			changeAddr)
+	}
+
+	// Build the ticket with placeholder commitment and change amounts in
+	// order to calculate its size.
+	tx := wire.NewMsgTx()
+	voteScriptVer, voteScript := stakeAddr.VotingRightsScript()
+	tx.AddTxOut(newTxOut(int64(ticketPrice), voteScriptVer, voteScript))
+	commitScriptVer, commitScript := stakeAddr.RewardCommitmentScript(0, 0,
+		revokeFeeLimit)
+	tx.AddTxOut(newTxOut(0, commitScriptVer, commitScript))
+	changeScriptVer, changeScript := stakeChangeAddr.StakeChangeScript()
+	tx.AddTxOut(newTxOut(0, changeScriptVer, changeScript))
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, 0, nil))
+	fee := dcrutil.Amount(tx.SerializeSize()+spendSize) * feeRate
+
+	var outPoint wire.OutPoint
+	var selected *utxo
+	for op, utxo := range m.utxos {
+		if !utxo.isMature(m.currentHeight) || utxo.isLocked {
+			continue
+		}
+		if utxo.value >= ticketPrice+fee {
+			outPoint, selected = op, utxo
+			break
+		}
+	}
+	if selected == nil {
+		return nil, fmt.Errorf("no output with at least %v to purchase "+
+			"a ticket", ticketPrice+fee)
+	}
+
+	// The commitment is the amount contributed to the ticket, that is, the
+	// ticket price and fee.
+	tx.TxIn[0] = wire.NewTxIn(&outPoint, int64(selected.value), nil)
+	commitScriptVer, commitScript = stakeAddr.RewardCommitmentScript(
+		int64(ticketPrice+fee), 0, revokeFeeLimit)
+	tx.TxOut[1] = newTxOut(0, commitScriptVer, commitScript)
+	tx.TxOut[2].Value = int64(selected.value - ticketPrice - fee)
+
+	extendedKey, err := m.hdRoot.Child(selected.keyIndex)
+	if err != nil {
+		return nil, err
+	}
+	privKey, err := extendedKey.SerializedPrivKey()
+	if err != nil {
+		return nil, err
+	}
+	sigScript, err := sign.SignatureScript(tx, 0, selected.pkScript,
+		txscript.SigHashAll, privKey, dcrec.STEcdsaSecp256k1, true)
+	if err != nil {
+		return nil, err
+	}
+	tx.TxIn[0].SignatureScript = sigScript
+
+	// Lock the output in order to avoid double spending it. It may be
+	// freed via a call to UnlockOutputs.
+	selected.isLocked = true
+
+	return tx, nil
+}
+
 // UnlockOutputs unlocks any outputs which were previously locked due to
 // being selected to fund a transaction via the CreateTransaction method.
 //
@@ -573,6 +708,64 @@ func (m *memWallet) UnlockOutputs(inputs []*wire.TxIn) {
 	}
 }
 
+// addresses returns all addresses controlled by the wallet.
+//
+// This function is safe for concurrent access.
+func (m *memWallet) addresses() []stdaddr.Address {
+	m.RLock()
+	defer m.RUnlock()
+
+	addrs := make([]stdaddr.Address, 0, len(m.addrs))
+	for _, addr := range m.addrs {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// unlockAllOutputs unlocks every output which was previously locked due to
+// being selected to fund a transaction via the CreateTransaction method.
+//
+// This function is safe for concurrent access.
+func (m *memWallet) unlockAllOutputs() {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, utxo := range m.utxos {
+		utxo.isLocked = false
+	}
+}
+
+// reset forgets all blocks and outputs known to the wallet, returning it to
+// the state of a wallet synced only to the genesis block. The addresses of the
+// wallet are preserved.
+//
+// This function is safe for concurrent access.
+func (m *memWallet) reset() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.currentHeight = 0
+	m.utxos = make(map[wire.OutPoint]*utxo)
+	m.reorgJournal = make(map[int64]*undoEntry)
+}
+
+// numMatureOutputs returns the number of mature and unlocked outputs of the
+// wallet.
+//
+// This function is safe for concurrent access.
+func (m *memWallet) numMatureOutputs() int {
+	m.RLock()
+	defer m.RUnlock()
+
+	var n int
+	for _, utxo := range m.utxos {
+		if utxo.isMature(m.currentHeight) && !utxo.isLocked {
+			n++
+		}
+	}
+	return n
+}
+
 // ConfirmedBalance returns the confirmed balance of the wallet.
 //
 // This function is safe for concurrent access.
@@ -597,6 +790,32 @@ func (m *memWallet) ConfirmedBalance() dcrutil.Amount {
 	return balance
 }
 
+// BalanceEquals returns an error if the confirmed balance of the wallet differs
+// from want by more than tolerance. A non-zero tolerance allows comparing
+// balances after sending transactions whose fees are not known in advance.
+//
+// This function is safe for concurrent access.
+func (m *memWallet) BalanceEquals(want, tolerance dcrutil.Amount) error {
+	got := m.ConfirmedBalance()
+	diff := got - want
+	if diff < -tolerance || diff > tolerance {
+		return fmt.Errorf("unexpected confirmed balance: got %v, want %v "+
+			"(diff %v, tolerance %v)", got, want, diff, tolerance)
+	}
+	return nil
+}
+
+// AssertBalance fails the test if the confirmed balance of the wallet differs
+// from want by more than tolerance. See BalanceEquals.
+//
+// This function is safe for concurrent access.
+func (m *memWallet) AssertBalance(t testing.TB, want, tolerance dcrutil.Amount) {
+	t.Helper()
+	if err := m.BalanceEquals(want, tolerance); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // keyToAddr maps the passed private to corresponding p2pkh address.
 func keyToAddr(serializedPrivKey []byte, net *chaincfg.Params) (stdaddr.Address, error) {
 	key := secp256k1.PrivKeyFromBytes(serializedPrivKey)
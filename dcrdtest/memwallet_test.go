@@ -0,0 +1,83 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg/v3"
+	"github.com/decred/dcrd/dcrutil/v4"
+	"github.com/decred/dcrd/wire"
+)
+
+// TestBalanceEquals ensures BalanceEquals only considers mature and unlocked
+// outputs and respects the tolerance.
+func TestBalanceEquals(t *testing.T) {
+	m := &memWallet{
+		currentHeight: 10,
+		utxos: map[wire.OutPoint]*utxo{
+			{Index: 0}: {value: 1e8, maturityHeight: 5},
+			{Index: 1}: {value: 2e8, maturityHeight: 10},
+			{Index: 2}: {value: 4e8, maturityHeight: 11},
+			{Index: 3}: {value: 8e8, maturityHeight: 5, isLocked: true},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		want      dcrutil.Amount
+		tolerance dcrutil.Amount
+		wantErr   bool
+	}{
+		{"exact", 3e8, 0, false},
+		{"below within tolerance", 3e8 + 1000, 1000, false},
+		{"above within tolerance", 3e8 - 1000, 1000, false},
+		{"below tolerance", 3e8 + 1001, 1000, true},
+		{"above tolerance", 3e8 - 1001, 1000, true},
+		{"includes immature", 7e8, 0, true},
+	}
+	for _, test := range tests {
+		err := m.BalanceEquals(test.want, test.tolerance)
+		if test.wantErr != (err != nil) {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "got 3 DCR") {
+			t.Fatalf("%s: error does not include the balance: %v",
+				test.name, err)
+		}
+	}
+}
+
+// TestMemWalletHDSeed ensures the keys of the wallet are deterministic given
+// the HD seed, regardless of the harness ID.
+func TestMemWalletHDSeed(t *testing.T) {
+	net := chaincfg.SimNetParams()
+	newWallet := func(id uint32, seed []byte) *memWallet {
+		t.Helper()
+		m, err := newMemWallet(t, net, id, seed)
+		if err != nil {
+			t.Fatalf("unable to create wallet: %v", err)
+		}
+		return m
+	}
+
+	// Without a seed, the keys depend on the harness ID.
+	if newWallet(0, nil).coinbaseAddr.String() ==
+		newWallet(1, nil).coinbaseAddr.String() {
+		t.Fatal("wallets of different harnesses share coinbase address")
+	}
+
+	seed := bytes.Repeat([]byte{0x01}, 32)
+	m1, m2 := newWallet(0, seed), newWallet(1, seed)
+	if m1.coinbaseAddr.String() != m2.coinbaseAddr.String() {
+		t.Fatalf("mismatched coinbase addresses: %v != %v",
+			m1.coinbaseAddr, m2.coinbaseAddr)
+	}
+	if m1.hdRoot.String() != m2.hdRoot.String() {
+		t.Fatal("mismatched HD roots")
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/decred/dcrd/blockchain/standalone/v2"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v3"
+	"github.com/decred/dcrd/wire"
+)
+
+// maxBlockTimeOffset is the max amount of time dcrd allows the timestamp of a
+// block to be ahead of its own clock.
+const maxBlockTimeOffset = 2 * time.Hour
+
+// solveHeader finds a nonce for which the header hash satisfies its target
+// difficulty.
+func solveHeader(ctx context.Context, header *wire.BlockHeader, net *chaincfg.Params) error {
+	for nonce := uint32(0); ; nonce++ {
+		// Periodically check whether the context is done, since solving
+		// may take a long time for higher difficulties.
+		if nonce%(1<<16) == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+		}
+
+		header.Nonce = nonce
+		hash := header.BlockHash()
+		if standalone.CheckProofOfWork(&hash, header.Bits, net.PowLimit) == nil {
+			return nil
+		}
+		if nonce == math.MaxUint32 {
+			return errors.New("unable to find a nonce that solves the block")
+		}
+	}
+}
+
+// GenerateBlockAtTime mines a single block on top of the current tip, with
+// its header timestamp set to the specified time (truncated to seconds).
+// This allows precise tests of the consensus rules that depend on block
+// timestamps, such as the median time past and difficulty retargets.
+//
+// Since dcrd's generate does not allow setting the block timestamp, the block
+// is built by retrieving the current block template via getwork, changing its
+// timestamp, solving it in process and submitting it back. Solving uses the
+// BLAKE-256 proof of work, therefore this may only be used in networks where
+// the BLAKE3 proof of work agenda is not active.
+//
+// An error is returned if the timestamp is not after the median time past of
+// the tip, if it is too far in the future or if the node rejects the block for
+// any other reason.
+func (h *Harness) GenerateBlockAtTime(ctx context.Context, t time.Time) (*chainhash.Hash, error) {
+	h.miningMtx.Lock()
+	defer h.miningMtx.Unlock()
+
+	t = time.Unix(t.Unix(), 0)
+	mtp, err := h.MedianTimePast(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !t.After(mtp) {
+		return nil, fmt.Errorf("block timestamp %v is not after the "+
+			"median time past %v", t, mtp)
+	}
+	if maxTime := time.Now().Add(maxBlockTimeOffset); t.After(maxTime) {
+		return nil, fmt.Errorf("block timestamp %v is more than %v in "+
+			"the future", t, maxBlockTimeOffset)
+	}
+
+	work, err := h.Node.GetWork(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := hex.DecodeString(work.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < wire.MaxBlockHeaderPayload {
+		return nil, fmt.Errorf("getwork data is too short (%d bytes)",
+			len(data))
+	}
+	var header wire.BlockHeader
+	err = header.Deserialize(bytes.NewReader(data[:wire.MaxBlockHeaderPayload]))
+	if err != nil {
+		return nil, err
+	}
+
+	header.Timestamp = t
+	if err := solveHeader(ctx, &header, h.ActiveNet); err != nil {
+		return nil, err
+	}
+	headerBytes, err := header.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	copy(data, headerBytes)
+
+	accepted, err := h.Node.GetWorkSubmit(ctx, hex.EncodeToString(data))
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, fmt.Errorf("block with timestamp %v was rejected by "+
+			"the node", t)
+	}
+	hash := header.BlockHash()
+	return &hash, nil
+}
+
+// GenerateBlocks mines numBlocks blocks in the harness' node and returns the
+// hashes of the generated blocks.
+//
+// dcrd only services one discrete mining request at a time and refuses them
+// while its CPU miner is running, therefore concurrent calls to the mining
+// methods of the harness are serialized and, when the harness was created
+// with WithGenerateThreads, the CPU miner is paused while the blocks are
+// generated. The returned hashes are only those of the blocks produced by this
+// call, even if the tip of the chain is advanced by the CPU miner before or
+// after it.
+//
+// Blocks at or after the stake validation height must include votes, which
+// dcrd waits for before mining them. In order to fail instead of waiting
+// indefinitely, an error is returned when the blocks reach that height and
+// the node does not have enough live tickets for a majority of the votes. Note
+// that a wallet that votes with those tickets, such as VotingWallet, is still
+// required.
+func (h *Harness) GenerateBlocks(ctx context.Context, numBlocks uint32) (hashes []*chainhash.Hash, err error) {
+	h.miningMtx.Lock()
+	defer h.miningMtx.Unlock()
+
+	height, err := h.Node.GetBlockCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	svh := h.ActiveNet.StakeValidationHeight
+	if height+int64(numBlocks) >= svh {
+		liveTickets, err := h.Node.LiveTickets(ctx)
+		if err != nil {
+			return nil, err
+		}
+		minVotes := int(h.ActiveNet.TicketsPerBlock/2 + 1)
+		if len(liveTickets) < minVotes {
+			return nil, fmt.Errorf("unable to generate %d blocks from "+
+				"height %d: blocks at or after the stake validation "+
+				"height %d require at least %d votes, but there are "+
+				"only %d live tickets", numBlocks, height, svh,
+				minVotes, len(liveTickets))
+		}
+	}
+
+	if h.opts.generateThreads > 0 {
+		if err := h.Node.SetGenerate(ctx, false, 0); err != nil {
+			return nil, fmt.Errorf("unable to disable CPU mining: %w", err)
+		}
+		defer func() {
+			genErr := h.Node.SetGenerate(ctx, true, h.opts.generateThreads)
+			if genErr != nil && err == nil {
+				err = fmt.Errorf("unable to enable CPU mining: %w", genErr)
+			}
+		}()
+	}
+
+	return h.Node.Generate(ctx, numBlocks)
+}
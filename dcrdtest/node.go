@@ -8,15 +8,21 @@ package dcrdtest
 import (
 	"bufio"
 	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -27,8 +33,12 @@ import (
 // nodeConfig contains all the args, and data required to launch a dcrd process
 // and connect the rpc client to it.
 type nodeConfig struct {
-	rpcUser    string
-	rpcPass    string
+	rpcUser string
+	rpcPass string
+
+	rpcLimitUser string
+	rpcLimitPass string
+
 	listen     string
 	rpcListen  string
 	rpcConnect string
@@ -39,6 +49,27 @@ type nodeConfig struct {
 	extra      []string
 	prefix     string
 
+	dialTimeout  time.Duration
+	banDuration  time.Duration
+	noBanning    bool
+	banThreshold uint32
+	maxPeers     *int
+	maxSameIP    *int
+
+	rpcMaxConcurrentReqs int
+	rpcMaxClients        int
+	rpcMaxWebsockets     int
+	maxOrphanTxs         *int
+	blockMaxSize         uint32
+
+	configEntries map[string]string
+	noTxIndex     bool
+
+	noExistsAddrIndex bool
+	disableTLS        bool
+
+	env map[string]string
+
 	pathToDCRD   string
 	endpoint     string
 	certFile     string
@@ -46,6 +77,15 @@ type nodeConfig struct {
 	certificates []byte
 }
 
+const (
+	// wsEndpoint is the endpoint of the websocket RPC server of dcrd.
+	wsEndpoint = "ws"
+
+	// httpPostEndpoint is the endpoint of the HTTP POST RPC server of
+	// dcrd, which is its root path.
+	httpPostEndpoint = ""
+)
+
 // newConfig returns a newConfig with all default values.
 func newConfig(prefix, certFile, keyFile string, extra []string) (*nodeConfig, error) {
 	a := &nodeConfig{
@@ -56,7 +96,7 @@ func newConfig(prefix, certFile, keyFile string, extra []string) (*nodeConfig, e
 		extra:     extra,
 		prefix:    prefix,
 
-		endpoint: "ws",
+		endpoint: wsEndpoint,
 		certFile: certFile,
 		keyFile:  keyFile,
 	}
@@ -80,6 +120,31 @@ func (n *nodeConfig) setDefaults() error {
 	return nil
 }
 
+// configFilePath returns the path of the dcrd config file written for the node.
+func (n *nodeConfig) configFilePath() string {
+	return filepath.Join(n.prefix, "dcrd.conf")
+}
+
+// writeConfigFile writes the config file entries, sorted by key, to the dcrd
+// config file of the node. It does nothing when there are no entries.
+func (n *nodeConfig) writeConfigFile() error {
+	if n.configEntries == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(n.configEntries))
+	for key := range n.configEntries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("[Application Options]\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", key, n.configEntries[key])
+	}
+	return os.WriteFile(n.configFilePath(), []byte(b.String()), 0600)
+}
+
 // arguments returns an array of arguments that be used to launch the dcrd
 // process.
 func (n *nodeConfig) arguments() []string {
@@ -92,6 +157,14 @@ func (n *nodeConfig) arguments() []string {
 		// --rpcpass
 		args = append(args, fmt.Sprintf("--rpcpass=%s", n.rpcPass))
 	}
+	if n.rpcLimitUser != "" {
+		// --rpclimituser
+		args = append(args, fmt.Sprintf("--rpclimituser=%s", n.rpcLimitUser))
+	}
+	if n.rpcLimitPass != "" {
+		// --rpclimitpass
+		args = append(args, fmt.Sprintf("--rpclimitpass=%s", n.rpcLimitPass))
+	}
 	if n.listen != "" {
 		// --listen
 		args = append(args, fmt.Sprintf("--listen=%s", n.listen))
@@ -108,8 +181,18 @@ func (n *nodeConfig) arguments() []string {
 	args = append(args, fmt.Sprintf("--rpccert=%s", n.certFile))
 	// --rpckey
 	args = append(args, fmt.Sprintf("--rpckey=%s", n.keyFile))
-	// --txindex
-	args = append(args, "--txindex")
+	if n.disableTLS {
+		// --notls
+		args = append(args, "--notls")
+	}
+	if !n.noTxIndex {
+		// --txindex
+		args = append(args, "--txindex")
+	}
+	if n.noExistsAddrIndex {
+		// --noexistsaddrindex
+		args = append(args, "--noexistsaddrindex")
+	}
 	if n.dataDir != "" {
 		// --datadir
 		args = append(args, fmt.Sprintf("--datadir=%s", n.dataDir))
@@ -118,6 +201,11 @@ func (n *nodeConfig) arguments() []string {
 		// --logdir
 		args = append(args, fmt.Sprintf("--logdir=%s", n.logDir))
 	}
+	if n.configEntries != nil {
+		// --configfile
+		args = append(args, fmt.Sprintf("--configfile=%s",
+			n.configFilePath()))
+	}
 	if n.profile != "" {
 		// --profile
 		args = append(args, fmt.Sprintf("--profile=%s", n.profile))
@@ -126,6 +214,52 @@ func (n *nodeConfig) arguments() []string {
 		// --debuglevel
 		args = append(args, fmt.Sprintf("--debuglevel=%s", n.debugLevel))
 	}
+	if n.dialTimeout != 0 {
+		// --dialtimeout
+		args = append(args, fmt.Sprintf("--dialtimeout=%s", n.dialTimeout))
+	}
+	if n.banDuration != 0 {
+		// --banduration
+		args = append(args, fmt.Sprintf("--banduration=%s", n.banDuration))
+	}
+	if n.noBanning {
+		// --nobanning
+		args = append(args, "--nobanning")
+	}
+	if n.banThreshold != 0 {
+		// --banthreshold
+		args = append(args, fmt.Sprintf("--banthreshold=%d", n.banThreshold))
+	}
+	if n.maxPeers != nil {
+		// --maxpeers
+		args = append(args, fmt.Sprintf("--maxpeers=%d", *n.maxPeers))
+	}
+	if n.maxSameIP != nil {
+		// --maxsameip
+		args = append(args, fmt.Sprintf("--maxsameip=%d", *n.maxSameIP))
+	}
+	if n.rpcMaxConcurrentReqs != 0 {
+		// --rpcmaxconcurrentreqs
+		args = append(args, fmt.Sprintf("--rpcmaxconcurrentreqs=%d",
+			n.rpcMaxConcurrentReqs))
+	}
+	if n.rpcMaxClients != 0 {
+		// --rpcmaxclients
+		args = append(args, fmt.Sprintf("--rpcmaxclients=%d", n.rpcMaxClients))
+	}
+	if n.rpcMaxWebsockets != 0 {
+		// --rpcmaxwebsockets
+		args = append(args, fmt.Sprintf("--rpcmaxwebsockets=%d",
+			n.rpcMaxWebsockets))
+	}
+	if n.maxOrphanTxs != nil {
+		// --maxorphantx
+		args = append(args, fmt.Sprintf("--maxorphantx=%d", *n.maxOrphanTxs))
+	}
+	if n.blockMaxSize != 0 {
+		// --blockmaxsize
+		args = append(args, fmt.Sprintf("--blockmaxsize=%d", n.blockMaxSize))
+	}
 	// --allowunsyncedmining
 	args = append(args, "--allowunsyncedmining")
 	args = append(args, n.extra...)
@@ -134,27 +268,80 @@ func (n *nodeConfig) arguments() []string {
 
 // command returns the exec.Cmd which will be used to start the dcrd process.
 func (n *nodeConfig) command() *exec.Cmd {
-	return exec.Command(n.pathToDCRD, n.arguments()...)
+	cmd := exec.Command(n.pathToDCRD, n.arguments()...)
+	if n.env != nil {
+		cmd.Env = mergeEnv(os.Environ(), n.env)
+	}
+	return cmd
+}
+
+// mergeEnv returns the passed base environment, in the form used by
+// os.Environ, with the variables of env added to it, replacing the base
+// variables with the same name.
+func mergeEnv(base []string, env map[string]string) []string {
+	merged := make([]string, 0, len(base)+len(env))
+	for _, kv := range base {
+		key, _, _ := strings.Cut(kv, "=")
+		if _, ok := env[key]; !ok {
+			merged = append(merged, kv)
+		}
+	}
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		merged = append(merged, key+"="+env[key])
+	}
+	return merged
 }
 
 // rpcConnConfig returns the rpc connection config that can be used to connect
 // to the dcrd process that is launched via Start().
 func (n *nodeConfig) rpcConnConfig() rpc.ConnConfig {
 	return rpc.ConnConfig{
-		Host:                 n.rpcListen,
+		Host:                 dialAddr(n.rpcListen),
 		Endpoint:             n.endpoint,
+		HTTPPostMode:         n.endpoint != wsEndpoint,
 		User:                 n.rpcUser,
 		Pass:                 n.rpcPass,
 		Certificates:         n.certificates,
+		DisableTLS:           n.disableTLS,
 		DisableAutoReconnect: true,
 	}
 }
 
+// dialAddr returns the address used to connect to a node listening on the
+// passed address. Unspecified hosts, which make the node listen on all
+// interfaces, are replaced by the loopback address of the same family.
+func dialAddr(listenAddr string) string {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return listenAddr
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case host == "":
+		return net.JoinHostPort("127.0.0.1", port)
+	case ip == nil || !ip.IsUnspecified():
+		return listenAddr
+	case ip.To4() != nil:
+		return net.JoinHostPort("127.0.0.1", port)
+	default:
+		return net.JoinHostPort("::1", port)
+	}
+}
+
 // String returns the string representation of this nodeConfig.
 func (n *nodeConfig) String() string {
 	return n.prefix
 }
 
+// runningNodes is the number of dcrd processes launched by the package that
+// have not been stopped yet. It must only be accessed atomically.
+var runningNodes int32
+
 // node houses the necessary state required to configure, launch, and manage a
 // dcrd process.
 type node struct {
@@ -167,8 +354,44 @@ type node struct {
 	wg      sync.WaitGroup
 	pid     int
 
+	// running is true while the launched dcrd process has not been
+	// stopped.
+	running bool
+
+	// exited is closed once the launched dcrd process exits, after which
+	// exitErr holds the result of waiting for it.
+	exited  chan struct{}
+	exitErr error
+
+	// stateMtx protects the fields used to detect whether dcrd exited
+	// before the node was ready, which indicates the process exited early.
+	stateMtx    sync.Mutex
+	ready       bool
+	stopping    bool
+	earlyExit   error
+	onEarlyExit func(err error)
+
+	// outputTail holds the last lines output by dcrd, which are included
+	// in the early exit error.
+	outputTailMtx sync.Mutex
+	outputTail    []string
+
 	dataDir string
 
+	// stopTimeout is how long stop waits for the process to exit after
+	// interrupting it, before killing it. When zero, defaultStopTimeout is
+	// used.
+	stopTimeout time.Duration
+
+	// logSink, when non-nil, receives every line output by dcrd.
+	logSink *syncWriter
+
+	// logTaps are functions called with every line output by dcrd. They
+	// are used to wait for specific events logged by the node.
+	logTapsMtx sync.Mutex
+	logTaps    map[int]func(line string)
+	nextLogTap int
+
 	t *testing.T
 }
 
@@ -188,6 +411,129 @@ func (n *node) tracef(format string, args ...interface{}) {
 	tracef(n.t, pid+format, args...)
 }
 
+// syncWriter is an io.Writer that serializes writes to the underlying writer,
+// so that it can be shared among multiple goroutines.
+type syncWriter struct {
+	mtx sync.Mutex
+	w   io.Writer
+}
+
+// Write writes p to the underlying writer.
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.mtx.Lock()
+	defer sw.mtx.Unlock()
+	return sw.w.Write(p)
+}
+
+// maxOutputTail is the max number of the last lines output by dcrd included
+// in the early exit error.
+const maxOutputTail = 20
+
+// setReady marks the node as ready, meaning the RPC server of the dcrd
+// process was reached. The process exiting after this is not considered an
+// early exit.
+func (n *node) setReady() {
+	n.stateMtx.Lock()
+	n.ready = true
+	n.stateMtx.Unlock()
+}
+
+// earlyExitErr returns a non-nil error if the dcrd process exited before the
+// node was ready and before it was requested to stop.
+func (n *node) earlyExitErr() error {
+	n.stateMtx.Lock()
+	defer n.stateMtx.Unlock()
+	return n.earlyExit
+}
+
+// waitExit waits for the dcrd process to exit, which is expected after the
+// node is requested to stop. Exiting before the node is ready indicates the
+// process exited early (for example, due to an invalid config), which is
+// recorded, along with the exit status and the last lines output by dcrd, and
+// reported to the early exit handler, if any.
+//
+// This must be run as a goroutine once the process is started.
+func (n *node) waitExit() {
+	// Reads from the output streams must be completed before waiting for
+	// the process.
+	n.wg.Wait()
+	exitErr := n.cmd.Wait()
+
+	status := "unknown exit status"
+	if n.cmd.ProcessState != nil {
+		status = n.cmd.ProcessState.String()
+	}
+	n.tracef("process exited: %s", status)
+
+	n.stateMtx.Lock()
+	n.exitErr = exitErr
+	var err error
+	if !n.ready && !n.stopping {
+		n.outputTailMtx.Lock()
+		tail := strings.Join(n.outputTail, "")
+		n.outputTailMtx.Unlock()
+		err = fmt.Errorf("dcrd exited before the node was ready (%s); "+
+			"last output:\n%s", status, tail)
+		n.earlyExit = err
+	}
+	handler := n.onEarlyExit
+	n.stateMtx.Unlock()
+	close(n.exited)
+
+	if err == nil {
+		return
+	}
+	n.logf("%v", err)
+	if handler != nil {
+		handler(err)
+	}
+}
+
+// forwardLine forwards a line output by dcrd to the log sink, if one is
+// configured, and to all log taps.
+func (n *node) forwardLine(line []byte) {
+	if len(line) == 0 {
+		return
+	}
+
+	n.outputTailMtx.Lock()
+	n.outputTail = append(n.outputTail, string(line))
+	if len(n.outputTail) > maxOutputTail {
+		n.outputTail = n.outputTail[1:]
+	}
+	n.outputTailMtx.Unlock()
+
+	if n.logSink != nil {
+		if _, err := n.logSink.Write(line); err != nil {
+			n.tracef("log sink write error: %v", err)
+		}
+	}
+
+	n.logTapsMtx.Lock()
+	for _, tap := range n.logTaps {
+		tap(string(line))
+	}
+	n.logTapsMtx.Unlock()
+}
+
+// addLogTap registers f to be called with every line output by dcrd from now
+// on. Calls to f are serialized. The returned function removes the tap.
+func (n *node) addLogTap(f func(line string)) func() {
+	n.logTapsMtx.Lock()
+	defer n.logTapsMtx.Unlock()
+	if n.logTaps == nil {
+		n.logTaps = make(map[int]func(line string))
+	}
+	id := n.nextLogTap
+	n.nextLogTap++
+	n.logTaps[id] = f
+	return func() {
+		n.logTapsMtx.Lock()
+		delete(n.logTaps, id)
+		n.logTapsMtx.Unlock()
+	}
+}
+
 // newNode creates a new node instance according to the passed config. dataDir
 // will be used to hold a file recording the pid of the launched process, and
 // as the base for the log and data directories for dcrd. If pathToDCRD has a
@@ -196,10 +542,7 @@ func newNode(t *testing.T, config *nodeConfig, dataDir string) (*node, error) {
 	// Create the dcrd node used for tests if not created yet.
 	pathToDCRDMtx.Lock()
 	if pathToDCRD == "" {
-		pathToDCRD = "dcrd"
-		if runtime.GOOS == "windows" {
-			pathToDCRD += ".exe"
-		}
+		pathToDCRD = dcrdExecutable()
 	}
 	config.pathToDCRD = pathToDCRD
 	pathToDCRDMtx.Unlock()
@@ -219,6 +562,13 @@ func newNode(t *testing.T, config *nodeConfig, dataDir string) (*node, error) {
 func (n *node) start() error {
 	var err error
 
+	n.stateMtx.Lock()
+	n.ready, n.stopping, n.earlyExit = false, false, nil
+	n.stateMtx.Unlock()
+	n.outputTailMtx.Lock()
+	n.outputTail = nil
+	n.outputTailMtx.Unlock()
+
 	var pid sync.WaitGroup
 	pid.Add(1)
 
@@ -234,6 +584,7 @@ func (n *node) start() error {
 		r := bufio.NewReader(n.stderr)
 		for {
 			line, err := r.ReadBytes('\n')
+			n.forwardLine(line)
 			if errors.Is(err, io.EOF) {
 				n.tracef("stderr: EOF")
 				return
@@ -254,6 +605,7 @@ func (n *node) start() error {
 		r := bufio.NewReader(n.stdout)
 		for {
 			line, err := r.ReadBytes('\n')
+			n.forwardLine(line)
 			if errors.Is(err, io.EOF) {
 				n.tracef("stdout: EOF")
 				return
@@ -263,10 +615,16 @@ func (n *node) start() error {
 	}()
 
 	// Launch command and store pid.
+	debugf(n.t, "starting dcrd: %s",
+		strings.Join(redactArgs(n.cmd.Args), " "))
 	if err := n.cmd.Start(); err != nil {
 		return err
 	}
 	n.pid = n.cmd.Process.Pid
+	n.running = true
+	atomic.AddInt32(&runningNodes, 1)
+	n.exited = make(chan struct{})
+	go n.waitExit()
 
 	// Unblock pipes now pid is available
 	pid.Done()
@@ -284,9 +642,13 @@ func (n *node) start() error {
 	return f.Close()
 }
 
+// defaultStopTimeout is the default time stop waits for the dcrd process to
+// exit after interrupting it.
+const defaultStopTimeout = 15 * time.Second
+
 // stop interrupts the running dcrd process, and waits until it exits
 // properly. On windows, interrupt is not supported, so a kill signal is used
-// instead
+// instead. If the process does not exit within the stop timeout, it is killed.
 func (n *node) stop() error {
 	n.tracef("stop %p %p", n.cmd, n.cmd.Process)
 	defer n.tracef("stop done")
@@ -297,6 +659,10 @@ func (n *node) stop() error {
 		return nil
 	}
 
+	n.stateMtx.Lock()
+	n.stopping = true
+	n.stateMtx.Unlock()
+
 	// Send kill command
 	n.tracef("stop send kill")
 	var err error
@@ -306,18 +672,32 @@ func (n *node) stop() error {
 		err = n.cmd.Process.Signal(os.Interrupt)
 	}
 	if err != nil {
-		n.t.Logf("stop Signal error: %v", err)
+		logf(n.t, "stop Signal error: %v", err)
 	}
 
-	// Wait for pipes.
-	n.tracef("stop wg")
-	n.wg.Wait()
-
-	// Wait for command to exit.
-	n.tracef("stop cmd.Wait")
-	err = n.cmd.Wait()
-	if err != nil {
-		n.t.Logf("stop cmd.Wait error: %v", err)
+	// Wait for the command to exit, killing the process if it does not
+	// exit in a timely manner.
+	n.tracef("stop wait exit")
+	stopTimeout := n.stopTimeout
+	if stopTimeout == 0 {
+		stopTimeout = defaultStopTimeout
+	}
+	select {
+	case <-n.exited:
+	case <-time.After(stopTimeout):
+		logf(n.t, "dcrd did not exit %v after being interrupted, "+
+			"killing it", stopTimeout)
+		if err := n.cmd.Process.Kill(); err != nil {
+			logf(n.t, "stop Kill error: %v", err)
+		}
+		<-n.exited
+	}
+	if n.exitErr != nil {
+		logf(n.t, "stop cmd.Wait error: %v", n.exitErr)
+	}
+	if n.running {
+		n.running = false
+		atomic.AddInt32(&runningNodes, -1)
 	}
 	return nil
 }
@@ -331,7 +711,7 @@ func (n *node) cleanup() error {
 
 	if n.pidFile != "" {
 		if err := os.Remove(n.pidFile); err != nil {
-			n.t.Logf("unable to remove file %s: %v", n.pidFile,
+			logf(n.t, "unable to remove file %s: %v", n.pidFile,
 				err)
 			return err
 		}
@@ -347,18 +727,29 @@ func (n *node) shutdown() error {
 	defer n.tracef("shutdown done")
 
 	if err := n.stop(); err != nil {
-		n.t.Logf("shutdown stop error: %v", err)
+		logf(n.t, "shutdown stop error: %v", err)
 		return err
 	}
 	return n.cleanup()
 }
 
-// genCertPair generates a key/cert pair to the paths provided.
-func genCertPair(certFile, keyFile string) error {
+// defaultCertValidity is how long the generated TLS certs are valid for, unless
+// overridden with WithCertValidity.
+const defaultCertValidity = 10 * 365 * 24 * time.Hour
+
+// genCertPair generates a key/cert pair to the paths provided, using the passed
+// elliptic curve and validity. A nil curve and a zero validity select P-521
+// and defaultCertValidity respectively.
+func genCertPair(certFile, keyFile string, curve elliptic.Curve, validity time.Duration) error {
+	if curve == nil {
+		curve = elliptic.P521()
+	}
+	if validity == 0 {
+		validity = defaultCertValidity
+	}
 	org := "dcrdtest autogenerated cert"
-	validUntil := time.Now().Add(10 * 365 * 24 * time.Hour)
-	cert, key, err := certgen.NewTLSCertPair(elliptic.P521(), org,
-		validUntil, nil)
+	validUntil := time.Now().Add(validity)
+	cert, key, err := certgen.NewTLSCertPair(curve, org, validUntil, nil)
 	if err != nil {
 		return err
 	}
@@ -374,3 +765,53 @@ func genCertPair(certFile, keyFile string) error {
 
 	return nil
 }
+
+// checkCertValidity returns an error when the PEM encoded cert in certFile is
+// not valid at the passed time.
+func checkCertValidity(certFile string, now time.Time) error {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return fmt.Errorf("no PEM encoded cert found in %s", certFile)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("malformed cert in %s: %w", certFile, err)
+	}
+	if now.Before(cert.NotBefore) {
+		return fmt.Errorf("cert in %s is not valid until %v", certFile,
+			cert.NotBefore)
+	}
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf("cert in %s expired at %v", certFile,
+			cert.NotAfter)
+	}
+	return nil
+}
+
+// credentialArgs are the dcrd flags whose values are credentials, which must
+// never be logged nor returned by Harness.Args.
+var credentialArgs = []string{"--rpcpass", "--rpclimitpass"}
+
+// redactArgs returns a copy of the passed command line with the values of the
+// credential flags replaced by a placeholder, whether they are passed in the
+// form --flag=value or as a separate argument.
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	for i := 0; i < len(redacted); i++ {
+		for _, flag := range credentialArgs {
+			switch {
+			case strings.HasPrefix(redacted[i], flag+"="):
+				redacted[i] = flag + "=[redacted]"
+			case redacted[i] == flag && i+1 < len(redacted):
+				i++
+				redacted[i] = "[redacted]"
+			}
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,675 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/v3"
+	"github.com/decred/slog"
+)
+
+// hasArg returns true if the passed argument is found in args.
+func hasArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// TestHarnessOptionArguments ensures the harness options that map to dcrd
+// flags generate the expected command line arguments.
+func TestHarnessOptionArguments(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []HarnessOption
+		wantArgs   []string
+		wantNoArgs []string
+	}{{
+		name:     "peer connect timeout",
+		opts:     []HarnessOption{WithPeerConnectTimeout(5 * time.Second)},
+		wantArgs: []string{"--dialtimeout=5s"},
+	}, {
+		name:     "ban duration",
+		opts:     []HarnessOption{WithBanDuration(90 * time.Second)},
+		wantArgs: []string{"--banduration=1m30s"},
+	}, {
+		name:     "rpc max concurrent requests",
+		opts:     []HarnessOption{WithRPCMaxConcurrentRequests(100)},
+		wantArgs: []string{"--rpcmaxconcurrentreqs=100"},
+	}, {
+		name:     "zero max orphan txs",
+		opts:     []HarnessOption{WithMaxOrphanTxs(0)},
+		wantArgs: []string{"--maxorphantx=0"},
+	}, {
+		name:     "block max size",
+		opts:     []HarnessOption{WithBlockMaxSize(50000)},
+		wantArgs: []string{"--blockmaxsize=50000"},
+	}, {
+		name:     "rpc credentials",
+		opts:     []HarnessOption{WithRPCCredentials("alice", "secret")},
+		wantArgs: []string{"--rpcuser=alice", "--rpcpass=secret"},
+	}, {
+		name: "rpc limited user",
+		opts: []HarnessOption{WithRPCLimitedUser("bob", "other")},
+		wantArgs: []string{"--rpclimituser=bob",
+			"--rpclimitpass=other"},
+	}, {
+		name:     "config file",
+		opts:     []HarnessOption{WithConfigFile(map[string]string{"maxpeers": "5"})},
+		wantArgs: []string{"--configfile=dcrd.conf"},
+	}, {
+		name:     "debug level",
+		opts:     []HarnessOption{WithDebugLevel(slog.LevelDebug)},
+		wantArgs: []string{"--debuglevel=DBG"},
+	}, {
+		name: "subsystem debug levels",
+		opts: []HarnessOption{
+			WithSubsystemDebugLevel("RPCS", slog.LevelDebug),
+			WithSubsystemDebugLevel("MINR", slog.LevelTrace),
+		},
+		wantArgs: []string{"--debuglevel=MINR=TRC,RPCS=DBG"},
+	}, {
+		name: "global and subsystem debug levels",
+		opts: []HarnessOption{
+			WithDebugLevel(slog.LevelOff),
+			WithSubsystemDebugLevel("TXMP", slog.LevelTrace),
+		},
+		wantArgs: []string{"--debuglevel=ADXR=OFF,AMGR=OFF,BCDB=OFF," +
+			"CHAN=OFF,CMGR=OFF,DCRD=OFF,DISC=OFF,FEES=OFF,INDX=OFF," +
+			"MINR=OFF,PEER=OFF,RPCS=OFF,SCRP=OFF,SRVR=OFF,STKE=OFF," +
+			"SYNC=OFF,TRSY=OFF,TXMP=TRC"},
+	}, {
+		name: "listen addresses",
+		opts: []HarnessOption{
+			WithListenAddr("0.0.0.0:19555"),
+			WithRPCListenAddr("[::1]:19556"),
+		},
+		wantArgs: []string{"--listen=0.0.0.0:19555",
+			"--rpclisten=[::1]:19556"},
+	}, {
+		name:     "default tx index",
+		wantArgs: []string{"--txindex"},
+	}, {
+		name:       "disabled tx index",
+		opts:       []HarnessOption{WithTxIndex(false)},
+		wantNoArgs: []string{"--txindex"},
+	}, {
+		name:       "default exists address index",
+		wantNoArgs: []string{"--noexistsaddrindex"},
+	}, {
+		name:     "disabled exists address index",
+		opts:     []HarnessOption{WithExistsAddrIndex(false)},
+		wantArgs: []string{"--noexistsaddrindex"},
+	}, {
+		name: "rpc client limits",
+		opts: []HarnessOption{WithRPCMaxClients(50),
+			WithRPCMaxWebsockets(100)},
+		wantArgs: []string{"--rpcmaxclients=50",
+			"--rpcmaxwebsockets=100"},
+	}, {
+		name:       "default banning",
+		wantNoArgs: []string{"--nobanning"},
+	}, {
+		name: "peer management",
+		opts: []HarnessOption{WithNoBanning(), WithMaxPeers(0),
+			WithMaxSameIP(20)},
+		wantArgs: []string{"--nobanning", "--maxpeers=0",
+			"--maxsameip=20"},
+	}, {
+		name:     "ban threshold",
+		opts:     []HarnessOption{WithBanThreshold(10)},
+		wantArgs: []string{"--banthreshold=10"},
+	}, {
+		name:       "default TLS",
+		wantNoArgs: []string{"--notls"},
+	}, {
+		name:     "disabled TLS",
+		opts:     []HarnessOption{WithDisableTLS()},
+		wantArgs: []string{"--notls"},
+	}, {
+		name:     "profile port",
+		opts:     []HarnessOption{WithProfilePort(6060)},
+		wantArgs: []string{"--profile=127.0.0.1:6060"},
+	}}
+
+	for _, test := range tests {
+		var opts harnessOpts
+		for _, opt := range test.opts {
+			if err := opt(&opts); err != nil {
+				t.Fatalf("%s: unexpected error applying option: %v",
+					test.name, err)
+			}
+		}
+		var config nodeConfig
+		opts.applyNodeConfig(&config)
+		args := config.arguments()
+		for _, want := range test.wantArgs {
+			if !hasArg(args, want) {
+				t.Fatalf("%s: argument %q not found in %v", test.name,
+					want, args)
+			}
+		}
+		for _, unwanted := range test.wantNoArgs {
+			if hasArg(args, unwanted) {
+				t.Fatalf("%s: unexpected argument %q found in %v",
+					test.name, unwanted, args)
+			}
+		}
+	}
+}
+
+// TestHarnessOptionErrors ensures invalid harness options are rejected.
+func TestHarnessOptionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  HarnessOption
+	}{
+		{"nil rpc trace writer", WithRPCTracing(nil)},
+		{"nil log sink", WithLogSink(nil)},
+		{"nil early exit handler", WithEarlyExitHandler(nil)},
+		{"no mining addresses", WithMiningAddresses()},
+		{"nil mining address", WithMiningAddresses(nil)},
+		{"missing seed data dir", WithSeedDataDir("/nonexistent/dcrdtest")},
+		{"zero generate threads", WithGenerateThreads(0)},
+		{"zero peer connect timeout", WithPeerConnectTimeout(0)},
+		{"negative peer connect timeout", WithPeerConnectTimeout(-time.Second)},
+		{"sub-second peer connect timeout", WithPeerConnectTimeout(time.Millisecond)},
+		{"zero ban duration", WithBanDuration(0)},
+		{"sub-second ban duration", WithBanDuration(time.Millisecond)},
+		{"zero rpc max concurrent requests", WithRPCMaxConcurrentRequests(0)},
+		{"negative max orphan txs", WithMaxOrphanTxs(-1)},
+		{"zero slow RPC threshold", WithSlowRPCThreshold(0)},
+		{"zero stop timeout", WithStopTimeout(0)},
+		{"short HD seed", WithHDSeed(make([]byte, 15))},
+		{"long HD seed", WithHDSeed(make([]byte, 65))},
+		{"empty rpc user", WithRPCCredentials("", "pass")},
+		{"empty rpc pass", WithRPCCredentials("user", "")},
+		{"empty rpc limited user", WithRPCLimitedUser("", "pass")},
+		{"empty rpc limited pass", WithRPCLimitedUser("user", "")},
+		{"too small block max size", WithBlockMaxSize(999)},
+		{"empty config file", WithConfigFile(nil)},
+		{"empty env", WithEnv(nil)},
+		{"zero fallback fee rate", WithFallbackFeeRate(0)},
+		{"privileged profile port", WithProfilePort(80)},
+		{"nil cert curve", WithCertCurve(nil)},
+		{"unsupported cert curve", WithCertCurve(elliptic.P224())},
+		{"zero cert validity", WithCertValidity(0)},
+		{"unsupported rpc endpoint", WithRPCEndpoint("http")},
+		{"zero ban threshold", WithBanThreshold(0)},
+		{"zero rpc max clients", WithRPCMaxClients(0)},
+		{"missing test dir", WithTestDir("/nonexistent/dcrdtest")},
+		{"zero rpc max websockets", WithRPCMaxWebsockets(0)},
+		{"negative max peers", WithMaxPeers(-1)},
+		{"negative max same IP", WithMaxSameIP(-1)},
+		{"empty TLS files", WithTLSFiles("", "")},
+		{"missing TLS files", WithTLSFiles("/nonexistent/rpc.cert",
+			"/nonexistent/rpc.key")},
+		{"too large profile port", WithProfilePort(65536)},
+		{"env name with equals", WithEnv(map[string]string{"A=B": "1"})},
+		{"listen address without port", WithListenAddr("127.0.0.1")},
+		{"listen address with port 0", WithListenAddr("127.0.0.1:0")},
+		{"rpc listen address with invalid port", WithRPCListenAddr("127.0.0.1:x")},
+		{"invalid debug level", WithDebugLevel(slog.LevelOff + 1)},
+		{"unknown subsystem", WithSubsystemDebugLevel("RPC", slog.LevelDebug)},
+		{"invalid subsystem debug level", WithSubsystemDebugLevel("RPCS", slog.LevelOff+1)},
+		{"config file key with dashes", WithConfigFile(map[string]string{"--maxpeers": "5"})},
+		{"config file key with equals", WithConfigFile(map[string]string{"a=b": "5"})},
+		{"multiline config file value", WithConfigFile(map[string]string{"maxpeers": "5\nsimnet=1"})},
+	}
+
+	for _, test := range tests {
+		var opts harnessOpts
+		if err := test.opt(&opts); err == nil {
+			t.Fatalf("%s: expected an error", test.name)
+		}
+	}
+}
+
+// TestDialAddr ensures unspecified listening hosts are dialed via loopback.
+func TestDialAddr(t *testing.T) {
+	tests := []struct {
+		listen string
+		want   string
+	}{
+		{"127.0.0.1:19555", "127.0.0.1:19555"},
+		{"10.0.0.2:19555", "10.0.0.2:19555"},
+		{"localhost:19555", "localhost:19555"},
+		{":19555", "127.0.0.1:19555"},
+		{"0.0.0.0:19555", "127.0.0.1:19555"},
+		{"[::]:19555", "[::1]:19555"},
+	}
+	for _, test := range tests {
+		if got := dialAddr(test.listen); got != test.want {
+			t.Fatalf("%s: got %s, want %s", test.listen, got,
+				test.want)
+		}
+	}
+}
+
+// TestRPCEndpoint ensures the endpoint set with WithRPCEndpoint selects the
+// transport of the RPC config.
+func TestRPCEndpoint(t *testing.T) {
+	tests := []struct {
+		opts         []HarnessOption
+		wantEndpoint string
+		wantHTTPPost bool
+	}{
+		{nil, "ws", false},
+		{[]HarnessOption{WithRPCEndpoint("ws")}, "ws", false},
+		{[]HarnessOption{WithRPCEndpoint("")}, "", true},
+		{[]HarnessOption{WithRPCEndpoint(""), WithRPCEndpoint("ws")},
+			"ws", false},
+	}
+	for i, test := range tests {
+		var hopts harnessOpts
+		for _, opt := range test.opts {
+			if err := opt(&hopts); err != nil {
+				t.Fatalf("%d: unexpected option error: %v", i, err)
+			}
+		}
+		config := &nodeConfig{endpoint: wsEndpoint}
+		hopts.applyNodeConfig(config)
+		connConfig := config.rpcConnConfig()
+		if connConfig.Endpoint != test.wantEndpoint ||
+			connConfig.HTTPPostMode != test.wantHTTPPost {
+			t.Fatalf("%d: got endpoint %q (HTTP POST %v), want %q "+
+				"(HTTP POST %v)", i, connConfig.Endpoint,
+				connConfig.HTTPPostMode, test.wantEndpoint,
+				test.wantHTTPPost)
+		}
+	}
+}
+
+// TestNodeCommandEnv ensures the environment set with WithEnv is merged onto
+// the environment of the current process.
+func TestNodeCommandEnv(t *testing.T) {
+	var config nodeConfig
+	if cmd := config.command(); cmd.Env != nil {
+		t.Fatalf("unexpected env: %v", cmd.Env)
+	}
+
+	t.Setenv("DCRDTEST_ENV_KEPT", "kept")
+	t.Setenv("DCRDTEST_ENV_REPLACED", "old")
+	config.env = map[string]string{
+		"DCRDTEST_ENV_REPLACED": "new",
+		"GODEBUG":               "gctrace=1",
+	}
+	env := config.command().Env
+	for _, want := range []string{"DCRDTEST_ENV_KEPT=kept",
+		"DCRDTEST_ENV_REPLACED=new", "GODEBUG=gctrace=1"} {
+		if !hasArg(env, want) {
+			t.Fatalf("%q not found in %v", want, env)
+		}
+	}
+	if hasArg(env, "DCRDTEST_ENV_REPLACED=old") {
+		t.Fatalf("replaced variable found in %v", env)
+	}
+}
+
+// TestHarnessArgs ensures Args returns a copy of the full command line of the
+// dcrd process with the credentials redacted.
+func TestHarnessArgs(t *testing.T) {
+	config := &nodeConfig{
+		pathToDCRD:   "/path/to/dcrd",
+		rpcUser:      "user",
+		rpcPass:      "secretpass",
+		rpcLimitUser: "limited",
+		rpcLimitPass: "limitedpass",
+		extra:        []string{"--regnet"},
+	}
+	h := &Harness{node: &node{config: config, cmd: config.command()}}
+	args := h.Args()
+	if len(args) == 0 || args[0] != "/path/to/dcrd" {
+		t.Fatalf("unexpected executable in %v", args)
+	}
+	for _, want := range []string{"--rpcuser=user", "--regnet",
+		"--rpcpass=[redacted]", "--rpclimitpass=[redacted]"} {
+
+		if !hasArg(args[1:], want) {
+			t.Fatalf("arg %q not found in %v", want, args)
+		}
+	}
+	joined := strings.Join(args, " ")
+	if strings.Contains(joined, "secretpass") ||
+		strings.Contains(joined, "limitedpass") {
+
+		t.Fatalf("args contain credentials: %v", args)
+	}
+	redacted := redactArgs([]string{"dcrd", "--rpcpass", "secretpass"})
+	if redacted[2] != "[redacted]" {
+		t.Fatalf("separate credential arg not redacted: %v", redacted)
+	}
+	args[0] = "modified"
+	if h.Args()[0] != "/path/to/dcrd" {
+		t.Fatal("Args returned the args of the command")
+	}
+}
+
+// TestHarnessClientNil ensures Client returns a nil interface while the
+// harness is not connected to its node.
+func TestHarnessClientNil(t *testing.T) {
+	h := &Harness{}
+	if client := h.Client(); client != nil {
+		t.Fatalf("unexpected client %#v", client)
+	}
+}
+
+// TestHarnessCleanup ensures Cleanup removes the whole test dir only when the
+// harness created it, and otherwise only the paths the harness created in it.
+func TestHarnessCleanup(t *testing.T) {
+	// A caller provided dir keeps the dir itself and the paths which
+	// already existed.
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "data"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "keep"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := testDirPaths(dir, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, path := range paths {
+		if filepath.Base(path) == "data" {
+			t.Fatalf("existing path %s is owned by the harness", path)
+		}
+		if err := os.MkdirAll(path, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &Harness{node: &node{}, testNodeDir: dir, createdPaths: paths}
+	if err := h.Cleanup(); err != nil {
+		t.Fatalf("unable to clean up: %v", err)
+	}
+	for _, name := range []string{"data", "keep"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("existing path removed: %v", err)
+		}
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("created path %s not removed: %v", path, err)
+		}
+	}
+
+	// Files written by the harness must not already exist.
+	if _, err := testDirPaths(dir, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "rpc.cert"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDirPaths(dir, true); err == nil {
+		t.Fatal("expected an error with an existing cert")
+	}
+	if _, err := testDirPaths(dir, false); err != nil {
+		t.Fatalf("unexpected error with provided TLS files: %v", err)
+	}
+
+	// A harness owned dir is removed entirely.
+	h = &Harness{node: &node{}, testNodeDir: dir, ownsTestDir: true}
+	if err := h.Cleanup(); err != nil {
+		t.Fatalf("unable to clean up: %v", err)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("owned test dir not removed: %v", err)
+	}
+
+	// The files of a running node must not be removed.
+	h = &Harness{node: &node{running: true}, ownsTestDir: true}
+	if err := h.Cleanup(); err == nil {
+		t.Fatal("expected an error cleaning up a running node")
+	}
+}
+
+// TestHarnessDumpLogs ensures DumpLogs writes the log file dcrd creates for
+// the harness network.
+func TestHarnessDumpLogs(t *testing.T) {
+	h := &Harness{
+		ActiveNet: chaincfg.RegNetParams(),
+		node:      &node{config: &nodeConfig{logDir: t.TempDir()}},
+	}
+	var b strings.Builder
+	if err := h.DumpLogs(&b); err == nil {
+		t.Fatal("expected an error without a log file")
+	}
+
+	const log = "2022-11-14 12:00:00.000 [INF] DCRD: Version 1.8.0\n"
+	logDir := filepath.Join(h.node.config.logDir, "regnet")
+	if err := os.MkdirAll(logDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	err := os.WriteFile(filepath.Join(logDir, "dcrd.log"), []byte(log), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DumpLogs(&b); err != nil {
+		t.Fatalf("unable to dump logs: %v", err)
+	}
+	if b.String() != log {
+		t.Fatalf("unexpected log: got %q, want %q", b.String(), log)
+	}
+}
+
+// TestGenCertPair ensures the generated cert uses the requested curve and
+// validity.
+func TestGenCertPair(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "rpc.cert")
+	keyFile := filepath.Join(dir, "rpc.key")
+	err := genCertPair(certFile, keyFile, elliptic.P256(), time.Hour)
+	if err != nil {
+		t.Fatalf("unable to generate cert pair: %v", err)
+	}
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatal("unable to decode cert")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("unable to parse cert: %v", err)
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		t.Fatalf("unexpected public key type %T", cert.PublicKey)
+	}
+	if pub.Curve != elliptic.P256() {
+		t.Fatalf("unexpected curve %s", pub.Curve.Params().Name)
+	}
+	if time.Until(cert.NotAfter) > time.Hour {
+		t.Fatalf("cert valid until %v, want at most an hour",
+			cert.NotAfter)
+	}
+}
+
+// TestCheckCertValidity ensures certs are rejected when expired, not yet valid
+// or malformed.
+func TestCheckCertValidity(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "rpc.cert")
+	keyFile := filepath.Join(dir, "rpc.key")
+	if err := genCertPair(certFile, keyFile, nil, time.Hour); err != nil {
+		t.Fatalf("unable to generate cert pair: %v", err)
+	}
+
+	now := time.Now()
+	if err := checkCertValidity(certFile, now); err != nil {
+		t.Fatalf("unexpected error for valid cert: %v", err)
+	}
+	err := checkCertValidity(certFile, now.Add(2*time.Hour))
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("unexpected error for expired cert: %v", err)
+	}
+	err = checkCertValidity(certFile, now.Add(-48*time.Hour))
+	if err == nil || !strings.Contains(err.Error(), "not valid until") {
+		t.Fatalf("unexpected error for not yet valid cert: %v", err)
+	}
+	if err := checkCertValidity(keyFile, now); err == nil {
+		t.Fatal("expected an error for a file without a cert")
+	}
+}
+
+// TestNodeConfigFile ensures the config file entries are written sorted by key
+// under the dcrd application options section.
+func TestNodeConfigFile(t *testing.T) {
+	config := nodeConfig{
+		prefix: t.TempDir(),
+		configEntries: map[string]string{
+			"maxpeers":   "5",
+			"debuglevel": "debug",
+		},
+	}
+	if err := config.writeConfigFile(); err != nil {
+		t.Fatalf("unable to write config file: %v", err)
+	}
+	got, err := os.ReadFile(config.configFilePath())
+	if err != nil {
+		t.Fatalf("unable to read config file: %v", err)
+	}
+	want := "[Application Options]\ndebuglevel=debug\nmaxpeers=5\n"
+	if string(got) != want {
+		t.Fatalf("unexpected config file contents: got %q, want %q",
+			got, want)
+	}
+}
+
+// TestNodeHelperProcess is not a real test. It is used as a long running
+// process by TestNodeEarlyExit.
+func TestNodeHelperProcess(t *testing.T) {
+	if os.Getenv("DCRDTEST_HELPER_PROCESS") != "1" {
+		return
+	}
+	if os.Getenv("DCRDTEST_HELPER_IGNORE_INTERRUPT") == "1" {
+		signal.Ignore(os.Interrupt)
+	}
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+// TestNodeEarlyExit ensures a process exiting before the node is ready is
+// reported as an early exit, including its exit status and last output, while
+// exiting after it is ready or requested to stop is not.
+func TestNodeEarlyExit(t *testing.T) {
+	tests := []struct {
+		name      string
+		longLived bool
+		ready     bool
+		wantEarly bool
+	}{
+		{"exit before ready", false, false, true},
+		{"stop before ready", true, false, false},
+		{"stop after ready", true, true, false},
+	}
+
+	for _, test := range tests {
+		// The test binary itself is used as the process, which either
+		// exits right away or runs until it is stopped.
+		cmd := exec.Command(os.Args[0], "-test.run=^TestNodeHelperProcess$")
+		if test.longLived {
+			cmd.Env = append(os.Environ(), "DCRDTEST_HELPER_PROCESS=1")
+		}
+		handlerErr := make(chan error, 1)
+		n := &node{
+			config: &nodeConfig{prefix: t.TempDir()},
+			cmd:    cmd,
+			t:      t,
+			onEarlyExit: func(err error) {
+				handlerErr <- err
+			},
+		}
+		if err := n.start(); err != nil {
+			t.Fatalf("%s: unable to start process: %v", test.name, err)
+		}
+		if test.ready {
+			n.setReady()
+		}
+		if !test.longLived {
+			// Wait for the process to exit.
+			<-n.exited
+		}
+		if err := n.stop(); err != nil {
+			t.Fatalf("%s: unable to stop process: %v", test.name, err)
+		}
+
+		err := n.earlyExitErr()
+		if gotEarly := err != nil; gotEarly != test.wantEarly {
+			t.Fatalf("%s: unexpected early exit error: %v", test.name,
+				err)
+		}
+		if !test.wantEarly {
+			select {
+			case gotErr := <-handlerErr:
+				t.Fatalf("%s: unexpected handler call: %v",
+					test.name, gotErr)
+			default:
+			}
+			continue
+		}
+		if !strings.Contains(err.Error(), "exit status 0") ||
+			!strings.Contains(err.Error(), "PASS") {
+			t.Fatalf("%s: early exit error does not include the exit "+
+				"status and output: %v", test.name, err)
+		}
+		select {
+		case gotErr := <-handlerErr:
+			if gotErr != err {
+				t.Fatalf("%s: handler got %v, want %v", test.name,
+					gotErr, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: handler was not called", test.name)
+		}
+	}
+}
+
+// TestNodeStopTimeout ensures stop kills a process that does not exit after
+// being interrupted once the stop timeout elapses.
+func TestNodeStopTimeout(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestNodeHelperProcess$")
+	cmd.Env = append(os.Environ(), "DCRDTEST_HELPER_PROCESS=1",
+		"DCRDTEST_HELPER_IGNORE_INTERRUPT=1")
+	n := &node{
+		config:      &nodeConfig{prefix: t.TempDir()},
+		cmd:         cmd,
+		t:           t,
+		stopTimeout: 100 * time.Millisecond,
+	}
+	if err := n.start(); err != nil {
+		t.Fatalf("unable to start process: %v", err)
+	}
+
+	// Give the process time to ignore interrupts.
+	time.Sleep(500 * time.Millisecond)
+
+	start := time.Now()
+	if err := n.stop(); err != nil {
+		t.Fatalf("unable to stop process: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("stop took too long: %v", elapsed)
+	}
+	if cmd.ProcessState.Success() {
+		t.Fatalf("process was not killed: %v", cmd.ProcessState)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil/v4"
+	dcrdtypes "github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+	"github.com/decred/dcrd/wire"
+)
+
+const (
+	// blockNtfnBufferSize is the size of the buffer of the channels
+	// returned by NotifyBlocks.
+	blockNtfnBufferSize = 100
+
+	// txNtfnBufferSize is the size of the buffer of the channels returned
+	// by NotifyNewTransactions.
+	txNtfnBufferSize = 100
+)
+
+// BlockConnected is a notification of a block connected to the main chain of
+// the harness' node.
+type BlockConnected struct {
+	Header wire.BlockHeader
+
+	// Transactions are the transactions of the block relevant to the
+	// harness' internal wallet. This is not the full list of transactions
+	// of the block.
+	Transactions []*wire.MsgTx
+}
+
+// NotifyBlocks returns a channel which receives a notification for every block
+// connected to the main chain of the harness' node from this call onwards.
+// The channel is closed when the harness is torn down.
+//
+// The channel is buffered, but notifications are not allowed to block the
+// RPC client of the harness, therefore notifications are dropped (and the
+// fact logged) if the caller does not keep up with the connected blocks.
+//
+// This must be called after SetUp.
+func (h *Harness) NotifyBlocks() (<-chan *BlockConnected, error) {
+	if h.Node == nil {
+		return nil, errors.New("harness is not set up")
+	}
+
+	c := make(chan *BlockConnected, blockNtfnBufferSize)
+	h.blockNtfnsMtx.Lock()
+	h.blockNtfns = append(h.blockNtfns, c)
+	h.blockNtfnsMtx.Unlock()
+	return c, nil
+}
+
+// notifyBlockConnected sends a notification for the passed connected block
+// to every channel returned by NotifyBlocks.
+func (h *Harness) notifyBlockConnected(header []byte, filteredTxns [][]byte) {
+	h.blockNtfnsMtx.Lock()
+	defer h.blockNtfnsMtx.Unlock()
+	if len(h.blockNtfns) == 0 {
+		return
+	}
+
+	ntfn := new(BlockConnected)
+	if err := ntfn.Header.FromBytes(header); err != nil {
+		logf(h.t, "unable to decode connected block header: %v", err)
+		return
+	}
+	for _, txBytes := range filteredTxns {
+		tx, err := dcrutil.NewTxFromBytes(txBytes)
+		if err != nil {
+			logf(h.t, "unable to decode connected block tx: %v", err)
+			return
+		}
+		ntfn.Transactions = append(ntfn.Transactions, tx.MsgTx())
+	}
+
+	for _, c := range h.blockNtfns {
+		select {
+		case c <- ntfn:
+		default:
+			logf(h.t, "dropped block connected notification for "+
+				"block %v: channel is full", ntfn.Header.BlockHash())
+		}
+	}
+}
+
+// closeBlockNtfns closes every channel returned by NotifyBlocks.
+func (h *Harness) closeBlockNtfns() {
+	h.blockNtfnsMtx.Lock()
+	defer h.blockNtfnsMtx.Unlock()
+	for _, c := range h.blockNtfns {
+		close(c)
+	}
+	h.blockNtfns = nil
+}
+
+// TxAccepted is a notification of a transaction accepted into the mempool of
+// the harness' node.
+type TxAccepted struct {
+	Hash chainhash.Hash
+
+	// Amount is the sum of the output amounts of the transaction. It is
+	// only set for non-verbose notifications.
+	Amount dcrutil.Amount
+
+	// Details are the details of the transaction as returned by
+	// getrawtransaction. They are only set for verbose notifications.
+	Details *dcrdtypes.TxRawResult
+}
+
+// NotifyNewTransactions registers the harness' node to send notifications of
+// the transactions accepted into its mempool and returns a channel which
+// receives them from this call onwards. When verbose is true, the
+// notifications include the details of the transactions. The channel is
+// closed when the harness is torn down.
+//
+// dcrd only tracks a single verbosity per client, therefore every call made
+// while a previously returned channel is still open must request the same
+// verbosity.
+//
+// The channel is buffered, but notifications are not allowed to block the
+// RPC client of the harness, therefore notifications are dropped (and the
+// fact logged) if the caller does not keep up with the accepted transactions.
+//
+// This must be called after SetUp.
+func (h *Harness) NotifyNewTransactions(ctx context.Context, verbose bool) (<-chan *TxAccepted, error) {
+	if h.Node == nil {
+		return nil, errors.New("harness is not set up")
+	}
+
+	h.txNtfnsMtx.Lock()
+	registered := len(h.txNtfns) > 0
+	registeredVerbose := h.txNtfnsVerbose
+	h.txNtfnsMtx.Unlock()
+	if registered && verbose != registeredVerbose {
+		return nil, fmt.Errorf("transaction notifications already "+
+			"registered with verbose=%v", registeredVerbose)
+	}
+
+	// The mutex is not held during the call, since the notification
+	// handlers acquire it from the goroutine which processes the reply.
+	if !registered {
+		if err := h.Node.NotifyNewTransactions(ctx, verbose); err != nil {
+			return nil, err
+		}
+	}
+
+	c := make(chan *TxAccepted, txNtfnBufferSize)
+	h.txNtfnsMtx.Lock()
+	h.txNtfns = append(h.txNtfns, c)
+	h.txNtfnsVerbose = verbose
+	h.txNtfnsMtx.Unlock()
+	return c, nil
+}
+
+// notifyTxAccepted sends the passed notification to every channel returned by
+// NotifyNewTransactions.
+func (h *Harness) notifyTxAccepted(ntfn *TxAccepted) {
+	h.txNtfnsMtx.Lock()
+	defer h.txNtfnsMtx.Unlock()
+	for _, c := range h.txNtfns {
+		select {
+		case c <- ntfn:
+		default:
+			logf(h.t, "dropped tx accepted notification for tx %v: "+
+				"channel is full", ntfn.Hash)
+		}
+	}
+}
+
+// reregisterTxNtfns registers the harness' node to send transaction
+// notifications again, in case any channel returned by NotifyNewTransactions
+// is open. This is required after the node is restarted.
+func (h *Harness) reregisterTxNtfns(ctx context.Context) error {
+	h.txNtfnsMtx.Lock()
+	registered := len(h.txNtfns) > 0
+	verbose := h.txNtfnsVerbose
+	h.txNtfnsMtx.Unlock()
+	if !registered {
+		return nil
+	}
+	return h.Node.NotifyNewTransactions(ctx, verbose)
+}
+
+// closeTxNtfns closes every channel returned by NotifyNewTransactions.
+func (h *Harness) closeTxNtfns() {
+	h.txNtfnsMtx.Lock()
+	defer h.txNtfnsMtx.Unlock()
+	for _, c := range h.txNtfns {
+		close(c)
+	}
+	h.txNtfns = nil
+}
@@ -0,0 +1,1038 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"crypto/elliptic"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/dcrutil/v4"
+	"github.com/decred/dcrd/hdkeychain/v3"
+	"github.com/decred/dcrd/txscript/v4/stdaddr"
+	"github.com/decred/slog"
+)
+
+// harnessOpts houses the optional settings that may be specified when
+// creating a new Harness.
+type harnessOpts struct {
+	// rpcTraceWriter is the writer that receives the trace of all RPC
+	// calls made through the harness-created clients. When nil, RPC
+	// tracing is disabled.
+	rpcTraceWriter io.Writer
+
+	// slowRPCThreshold is the duration above which RPC calls made through
+	// the harness-created clients are reported as slow. When zero, slow
+	// calls are not reported.
+	slowRPCThreshold time.Duration
+
+	// generateThreads is the number of CPU mining threads to enable once
+	// the harness is set up. When zero, the CPU miner is not enabled.
+	generateThreads int
+
+	// dialTimeout is the timeout used by dcrd when establishing outbound
+	// peer connections. When zero, dcrd's default is used.
+	dialTimeout time.Duration
+
+	// banDuration is how long dcrd bans misbehaving peers. When zero,
+	// dcrd's default is used.
+	banDuration time.Duration
+
+	// noBanning disables the banning of misbehaving peers by dcrd.
+	noBanning bool
+
+	// banThreshold is the ban score at which dcrd disconnects and bans
+	// misbehaving peers. When zero, dcrd's default is used.
+	banThreshold uint32
+
+	// maxPeers and maxSameIP are the max number of peers of dcrd, in total
+	// and from the same IP. When nil, dcrd's defaults are used.
+	maxPeers  *int
+	maxSameIP *int
+
+	// rpcMaxConcurrentReqs is the max number of RPC requests dcrd
+	// processes concurrently. When zero, dcrd's default is used.
+	rpcMaxConcurrentReqs int
+
+	// rpcMaxClients and rpcMaxWebsockets are the max number of standard
+	// and websocket RPC clients dcrd serves at once. When zero, dcrd's
+	// defaults are used.
+	rpcMaxClients    int
+	rpcMaxWebsockets int
+
+	// maxOrphanTxs is the max number of orphan transactions dcrd keeps in
+	// its mempool. When nil, dcrd's default is used.
+	maxOrphanTxs *int
+
+	// blockMaxSize is the max size of the blocks created by dcrd. When
+	// zero, dcrd's default is used.
+	blockMaxSize uint32
+
+	// stopTimeout is how long to wait for dcrd to exit after interrupting
+	// it, before killing it. When zero, a default timeout is used.
+	stopTimeout time.Duration
+
+	// hdSeed is the HD seed of the internal wallet. When nil, a seed
+	// derived from the harness ID is used.
+	hdSeed []byte
+
+	// rpcUser and rpcPass are the credentials of the admin RPC user. When
+	// empty, default credentials are used.
+	rpcUser, rpcPass string
+
+	// rpcLimitUser and rpcLimitPass are the credentials of the limited RPC
+	// user. When empty, the limited RPC user is disabled.
+	rpcLimitUser, rpcLimitPass string
+
+	// configEntries are the entries of the dcrd config file written for
+	// the node. When nil, no config file is written.
+	configEntries map[string]string
+
+	// debugLevel is the log level of all dcrd subsystems. When nil, dcrd's
+	// default is used.
+	debugLevel *slog.Level
+
+	// subsystemLevels are the log levels of individual dcrd subsystems,
+	// which override debugLevel.
+	subsystemLevels map[string]slog.Level
+
+	// noTxIndex disables the transaction index of the node, which is
+	// enabled by default.
+	noTxIndex bool
+
+	// noExistsAddrIndex disables the exists address index of the node,
+	// which is enabled by default.
+	noExistsAddrIndex bool
+
+	// httpPostMode makes the RPC config returned by RPCConfig use HTTP
+	// POST requests instead of websockets.
+	httpPostMode bool
+
+	// disableTLS disables TLS for the RPC server of the node and the RPC
+	// clients of the harness.
+	disableTLS bool
+
+	// env are the environment variables set for the dcrd process, on top
+	// of the environment of the current process.
+	env map[string]string
+
+	// listen and rpcListen are the P2P and RPC listening addresses of the
+	// node. When empty, loopback addresses are chosen by the harness.
+	listen, rpcListen string
+
+	// dumpLogsOnFailure causes the dcrd log file to be written to the test
+	// log on TearDown when the test failed.
+	dumpLogsOnFailure bool
+
+	// fallbackFeeRate is the fee rate returned by EstimateFeeRate when the
+	// node is unable to estimate fees. When zero, a default is used.
+	fallbackFeeRate dcrutil.Amount
+
+	// profilePort is the port of the HTTP profiling server of the node.
+	// When nil, the profiling server is disabled and when zero, a free
+	// port is chosen.
+	profilePort *int
+
+	// testDir is the caller provided dir where the harness stores the
+	// files of the node. When empty, a temp dir is created.
+	testDir string
+
+	// certCurve and certValidity are the elliptic curve and validity of
+	// the generated TLS cert of the RPC server. When nil and zero, P-521
+	// and a 10 year validity are used.
+	certCurve    elliptic.Curve
+	certValidity time.Duration
+
+	// tlsCertFile and tlsKeyFile are pre-existing TLS cert and key files
+	// used for the RPC server instead of generating new ones.
+	// strictTLSFiles requires the cert in tlsCertFile to be currently
+	// valid.
+	tlsCertFile    string
+	tlsKeyFile     string
+	strictTLSFiles bool
+
+	// logSink receives the merged stdout and stderr output of dcrd. When
+	// nil, the output is not forwarded anywhere besides the test log.
+	logSink io.Writer
+
+	// onEarlyExit is called when the dcrd process exits before the node
+	// is ready.
+	onEarlyExit func(err error)
+
+	// miningAddrs are the addresses, besides the wallet coinbase address,
+	// which blocks mined by the node may pay to.
+	miningAddrs []stdaddr.Address
+
+	// seedDataDir is a dir whose contents are copied into the data dir of
+	// the node before it is launched.
+	seedDataDir string
+}
+
+// applyNodeConfig applies the options which translate directly into dcrd
+// settings to the passed node config.
+func (opts *harnessOpts) applyNodeConfig(config *nodeConfig) {
+	config.dialTimeout = opts.dialTimeout
+	config.banDuration = opts.banDuration
+	config.noBanning = opts.noBanning
+	config.banThreshold = opts.banThreshold
+	config.maxPeers = opts.maxPeers
+	config.maxSameIP = opts.maxSameIP
+	config.rpcMaxConcurrentReqs = opts.rpcMaxConcurrentReqs
+	config.rpcMaxClients = opts.rpcMaxClients
+	config.rpcMaxWebsockets = opts.rpcMaxWebsockets
+	if opts.rpcUser != "" {
+		config.rpcUser = opts.rpcUser
+		config.rpcPass = opts.rpcPass
+	}
+	config.rpcLimitUser = opts.rpcLimitUser
+	config.rpcLimitPass = opts.rpcLimitPass
+	config.maxOrphanTxs = opts.maxOrphanTxs
+	config.blockMaxSize = opts.blockMaxSize
+	config.configEntries = opts.configEntries
+	config.debugLevel = opts.debugLevelArg()
+	config.noTxIndex = opts.noTxIndex
+	config.noExistsAddrIndex = opts.noExistsAddrIndex
+	config.disableTLS = opts.disableTLS
+	if opts.httpPostMode {
+		config.endpoint = httpPostEndpoint
+	}
+	config.env = opts.env
+	if opts.profilePort != nil && *opts.profilePort != 0 {
+		config.profile = net.JoinHostPort("127.0.0.1",
+			strconv.Itoa(*opts.profilePort))
+	}
+	if opts.listen != "" {
+		config.listen = opts.listen
+	}
+	if opts.rpcListen != "" {
+		config.rpcListen = opts.rpcListen
+	}
+}
+
+// debugLevelArg returns the value of the dcrd --debuglevel flag that
+// corresponds to the global and per-subsystem log levels of the options, or an
+// empty string when no levels were set.
+//
+// dcrd does not accept a global level combined with per-subsystem ones, so
+// when any subsystem level is set, the global level is expanded to every
+// subsystem that does not have its own level.
+func (opts *harnessOpts) debugLevelArg() string {
+	if len(opts.subsystemLevels) == 0 {
+		if opts.debugLevel == nil {
+			return ""
+		}
+		return opts.debugLevel.String()
+	}
+
+	var pairs []string
+	for _, subsystem := range dcrdSubsystems {
+		level, ok := opts.subsystemLevels[subsystem]
+		if !ok {
+			if opts.debugLevel == nil {
+				continue
+			}
+			level = *opts.debugLevel
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%s", subsystem, level))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// dcrdSubsystems are the logging subsystems of dcrd, sorted by name.
+var dcrdSubsystems = []string{
+	"ADXR", "AMGR", "BCDB", "CHAN", "CMGR", "DCRD", "DISC", "FEES", "INDX",
+	"MINR", "PEER", "RPCS", "SCRP", "SRVR", "STKE", "SYNC", "TRSY", "TXMP",
+}
+
+// HarnessOption is a functional option that modifies the settings of a
+// Harness during its creation via New.
+type HarnessOption func(*harnessOpts) error
+
+// WithRPCTracing causes the harness to log every RPC request, along with its
+// params and the corresponding response or error, made through the RPC
+// clients created by the harness (including any client created from the
+// config returned by RPCConfig) to the passed writer.
+//
+// This is done by routing the RPC connections through an in-process proxy, so
+// the trace reflects the exact timeline of the calls as seen by the dcrd
+// node. Credentials are never written to the trace.
+//
+// RPC tracing is disabled by default.
+func WithRPCTracing(w io.Writer) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if w == nil {
+			return errors.New("RPC tracing writer cannot be nil")
+		}
+		opts.rpcTraceWriter = w
+		return nil
+	}
+}
+
+// WithSlowRPCThreshold causes the harness to log a warning, which includes the
+// method and the duration of the call, for every RPC call made through the
+// RPC clients created by the harness that takes longer than d to be answered
+// by the dcrd node. This helps surfacing performance regressions of dcrd
+// during integration tests.
+//
+// Like WithRPCTracing, this routes the RPC connections through an in-process
+// proxy, so the reported duration is the one observed by the proxy.
+//
+// Slow RPC calls are not reported by default.
+func WithSlowRPCThreshold(d time.Duration) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if d <= 0 {
+			return fmt.Errorf("slow RPC threshold must be positive "+
+				"(got %v)", d)
+		}
+		opts.slowRPCThreshold = d
+		return nil
+	}
+}
+
+// WithGenerateThreads causes the harness to enable the CPU miner of the dcrd
+// node with the specified number of mining threads as the final step of
+// SetUp. dcrd does not provide a config flag for the number of mining
+// threads, so this is done via the setgenerate RPC, which also means the node
+// continuously mines blocks until the miner is disabled via SetGenerate(ctx,
+// false, 0).
+//
+// Note that dcrd refuses discrete generate calls while the CPU miner is
+// running, so tests using this option should not call Generate directly.
+// Harnesses always launch dcrd with --allowunsyncedmining, therefore the CPU
+// miner starts even though the node has no peers.
+func WithGenerateThreads(n int) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if n < 1 {
+			return fmt.Errorf("number of mining threads must be at "+
+				"least 1 (got %d)", n)
+		}
+		opts.generateThreads = n
+		return nil
+	}
+}
+
+// minDcrdDuration is the minimum value dcrd accepts for its duration config
+// flags.
+const minDcrdDuration = time.Second
+
+// WithPeerConnectTimeout sets how long the dcrd node waits for outbound peer
+// connections to be established before giving up (the --dialtimeout dcrd
+// flag). This is useful for tests that point the node to an unreachable
+// address and expect it to give up within a bounded time.
+//
+// dcrd requires the timeout to be at least one second.
+func WithPeerConnectTimeout(d time.Duration) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if d < minDcrdDuration {
+			return fmt.Errorf("peer connect timeout must be at least "+
+				"%v (got %v)", minDcrdDuration, d)
+		}
+		opts.dialTimeout = d
+		return nil
+	}
+}
+
+// WithBanDuration sets how long the dcrd node bans misbehaving peers (the
+// --banduration dcrd flag), which otherwise defaults to 24 hours. This allows
+// tests to verify that bans expire.
+//
+// dcrd requires the duration to be at least one second.
+func WithBanDuration(d time.Duration) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if d < minDcrdDuration {
+			return fmt.Errorf("ban duration must be at least %v "+
+				"(got %v)", minDcrdDuration, d)
+		}
+		opts.banDuration = d
+		return nil
+	}
+}
+
+// WithLogSink causes every line output by the dcrd node, in both its stdout
+// and stderr, to be written verbatim to the passed writer. This allows
+// forwarding the raw logs to a file, syslog or a remote log collector, for
+// example for centralized log aggregation in CI environments.
+//
+// The stdout and stderr lines are written from different goroutines, but the
+// writes are serialized by the harness, therefore the writer does not need to
+// be safe for concurrent use. Each call to Write receives exactly one line.
+func WithLogSink(w io.Writer) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if w == nil {
+			return errors.New("log sink writer cannot be nil")
+		}
+		opts.logSink = w
+		return nil
+	}
+}
+
+// WithSeedDataDir causes the contents of the passed dir to be copied into the
+// data dir of the dcrd node before it is first launched, so that the node
+// starts from the chain state stored in it (for example, a fixture with a
+// pre-built chain shipped with the tests). This is a raw copy of the node
+// databases and is thus much faster than mining or submitting the blocks.
+//
+// The dir must have the layout of a dcrd data dir, that is, it must contain a
+// subdir named after the harness network (e.g. "simnet") and New returns an
+// error if it does not.
+//
+// Note that the harness wallet only tracks blocks connected after SetUp, so
+// the outputs of the seeded chain are not spendable by it.
+func WithSeedDataDir(src string) HarnessOption {
+	return func(opts *harnessOpts) error {
+		info, err := os.Stat(src)
+		if err != nil {
+			return fmt.Errorf("invalid seed data dir: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("seed data dir %s is not a dir", src)
+		}
+		opts.seedDataDir = src
+		return nil
+	}
+}
+
+// WithRPCMaxConcurrentRequests sets the max number of RPC requests the dcrd
+// node processes concurrently (the --rpcmaxconcurrentreqs dcrd flag). Further
+// requests wait until previous ones are done, so tests that issue a large
+// number of concurrent or slow requests may raise this to avoid spurious
+// timeouts on the client side.
+//
+// Note that dcrd does not provide settings for the max size of RPC requests
+// (which is fixed at 8 MiB for HTTP POST and 16 MiB for websocket requests)
+// nor for a server-side request timeout.
+func WithRPCMaxConcurrentRequests(n int) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if n < 1 {
+			return fmt.Errorf("max number of concurrent RPC requests "+
+				"must be at least 1 (got %d)", n)
+		}
+		opts.rpcMaxConcurrentReqs = n
+		return nil
+	}
+}
+
+// WithEarlyExitHandler sets a function which is called when the dcrd process
+// exits before the node is ready, that is, before the harness is able to
+// connect to its RPC server. This typically happens due to an invalid config
+// or a failure to bind the listening addresses. The error passed to the
+// handler includes the exit status of the process and the last lines it
+// output.
+//
+// Regardless of this option, SetUp fails with a descriptive error in this
+// case instead of exhausting its RPC connection retries. The handler is
+// called from an internal goroutine and allows tests to react to the failure
+// as soon as it happens.
+func WithEarlyExitHandler(f func(err error)) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if f == nil {
+			return errors.New("early exit handler cannot be nil")
+		}
+		opts.onEarlyExit = f
+		return nil
+	}
+}
+
+// WithMiningAddresses adds the passed addresses to the set of addresses the
+// blocks mined by the dcrd node pay to (one --miningaddr dcrd flag per
+// address). This allows tests to spread coinbase rewards across several keys,
+// for example to set up multiple funded addresses.
+//
+// The coinbase address of the harness' internal wallet is always part of the
+// set, so the internal wallet keeps being funded. dcrd randomly selects one
+// address of the set for every block, therefore SetUp mines as many blocks as
+// needed for the internal wallet to have the requested number of mature
+// outputs. The rewards paid to the passed addresses are not tracked by the
+// internal wallet, since it does not control their keys.
+//
+// New returns an error if any of the addresses is not valid for the harness
+// network.
+func WithMiningAddresses(addrs ...stdaddr.Address) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if len(addrs) == 0 {
+			return errors.New("at least one mining address must be " +
+				"provided")
+		}
+		for _, addr := range addrs {
+			if addr == nil {
+				return errors.New("mining address cannot be nil")
+			}
+		}
+		opts.miningAddrs = append(opts.miningAddrs, addrs...)
+		return nil
+	}
+}
+
+// WithMaxOrphanTxs sets the max number of orphan transactions (those that
+// spend outputs of unknown transactions) the dcrd node keeps in its mempool
+// (the --maxorphantx dcrd flag). Zero disables keeping orphans entirely, which
+// causes the node to reject them.
+//
+// Note that dcrd does not provide a setting for the expiration of mempool
+// transactions.
+func WithMaxOrphanTxs(n int) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if n < 0 {
+			return fmt.Errorf("max number of orphan transactions "+
+				"cannot be negative (got %d)", n)
+		}
+		opts.maxOrphanTxs = &n
+		return nil
+	}
+}
+
+// minBlockMaxSize is the minimum value dcrd accepts for --blockmaxsize.
+const minBlockMaxSize = 1000
+
+// WithBlockMaxSize sets the max size in bytes of the blocks created by the
+// dcrd node when mining (the --blockmaxsize dcrd flag). This allows tests to
+// easily fill blocks and exercise the selection of transactions from the
+// mempool.
+//
+// dcrd requires the size to be at least 1000 bytes and at most the max block
+// size of the network minus 1000 bytes. The latter is checked by
+// Harness.Validate.
+func WithBlockMaxSize(size uint32) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if size < minBlockMaxSize {
+			return fmt.Errorf("max block size must be at least %d "+
+				"(got %d)", minBlockMaxSize, size)
+		}
+		opts.blockMaxSize = size
+		return nil
+	}
+}
+
+// WithStopTimeout sets how long the harness waits for the dcrd process to exit
+// after requesting it to shut down (for example, during TearDown), before
+// forcefully killing it. This ensures tests do not hang when dcrd itself hangs
+// during shutdown.
+//
+// The default timeout is 15 seconds.
+func WithStopTimeout(d time.Duration) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if d <= 0 {
+			return fmt.Errorf("stop timeout must be positive (got %v)", d)
+		}
+		opts.stopTimeout = d
+		return nil
+	}
+}
+
+// WithHDSeed sets the HD seed used to derive the keys of the harness' internal
+// wallet, including its coinbase address. The addresses and signing keys of
+// the wallet, and therefore the transactions it creates, are deterministic
+// given the seed, which allows replaying a failing test with identical
+// on-chain state.
+//
+// Passing the same seed to multiple harnesses is allowed, so harnesses that
+// share a seed also share their keys.
+//
+// By default, the seed is derived from a fixed seed and the sequential ID of
+// the harness within the process, so each harness has distinct keys which are
+// still the same across runs of the test.
+func WithHDSeed(seed []byte) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if len(seed) < hdkeychain.MinSeedBytes ||
+			len(seed) > hdkeychain.MaxSeedBytes {
+			return fmt.Errorf("HD seed length must be between %d and "+
+				"%d bytes (got %d)", hdkeychain.MinSeedBytes,
+				hdkeychain.MaxSeedBytes, len(seed))
+		}
+		opts.hdSeed = append([]byte(nil), seed...)
+		return nil
+	}
+}
+
+// WithRPCCredentials sets the username and password of the admin RPC user of
+// the dcrd node (the --rpcuser and --rpcpass dcrd flags). The RPC clients
+// created by the harness, including the config returned by RPCConfig, use
+// these credentials. This allows tests to integrate with external tools that
+// expect specific credentials or to exercise authentication failures.
+//
+// Both values must be non-empty, since dcrd disables the admin RPC user
+// otherwise. By default, the username "user" and password "pass" are used.
+func WithRPCCredentials(user, pass string) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if user == "" || pass == "" {
+			return errors.New("RPC username and password cannot be empty")
+		}
+		opts.rpcUser, opts.rpcPass = user, pass
+		return nil
+	}
+}
+
+// WithRPCLimitedUser enables the limited RPC user of the dcrd node with the
+// passed username and password (the --rpclimituser and --rpclimitpass dcrd
+// flags). The limited user may only issue the RPC calls dcrd considers safe
+// for untrusted clients, which allows tests to exercise authorization
+// boundaries. Clients authenticated as the limited user may be created with
+// Harness.LimitedRPCClient.
+//
+// Both values must be non-empty, since dcrd disables the limited RPC user
+// otherwise, and they must differ from the admin RPC credentials, which is
+// checked by Harness.Validate.
+func WithRPCLimitedUser(user, pass string) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if user == "" || pass == "" {
+			return errors.New("limited RPC username and password " +
+				"cannot be empty")
+		}
+		opts.rpcLimitUser, opts.rpcLimitPass = user, pass
+		return nil
+	}
+}
+
+// WithConfigFile causes the harness to write a dcrd.conf file with the passed
+// entries into the node dir and to point dcrd at it via --configfile. The keys
+// are long dcrd flag names without the leading dashes (for example,
+// "maxpeers"), and the values are written verbatim, so boolean flags should
+// be set to "1".
+//
+// dcrd applies the config file before parsing the command line, therefore the
+// flags set by the harness and its options, as well as any extra args passed
+// to New, take precedence over the entries of the file. Entries for flags that
+// accept multiple values are combined with the command line ones.
+func WithConfigFile(entries map[string]string) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if len(entries) == 0 {
+			return errors.New("config file entries cannot be empty")
+		}
+		cp := make(map[string]string, len(entries))
+		for key, value := range entries {
+			if key == "" || strings.HasPrefix(key, "-") ||
+				strings.ContainsAny(key, "= \t\r\n") {
+				return fmt.Errorf("invalid config file key %q", key)
+			}
+			if strings.ContainsAny(value, "\r\n") {
+				return fmt.Errorf("config file value of key %q "+
+					"spans multiple lines", key)
+			}
+			cp[key] = value
+		}
+		opts.configEntries = cp
+		return nil
+	}
+}
+
+// WithDebugLevel sets the log level of all dcrd subsystems (the --debuglevel
+// dcrd flag). It may be combined with WithSubsystemDebugLevel to override the
+// level of individual subsystems.
+//
+// By default, dcrd logs at the info level.
+func WithDebugLevel(level slog.Level) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if level > slog.LevelOff {
+			return fmt.Errorf("invalid debug level %d", level)
+		}
+		opts.debugLevel = &level
+		return nil
+	}
+}
+
+// WithSubsystemDebugLevel sets the log level of a single dcrd subsystem, such
+// as "RPCS" or "MINR", overriding the level set by WithDebugLevel. It may be
+// specified multiple times to set the level of several subsystems.
+//
+// The subsystem must be one of the logging subsystems of dcrd, so that typos
+// are reported when creating the harness instead of when launching the node.
+func WithSubsystemDebugLevel(subsystem string, level slog.Level) HarnessOption {
+	return func(opts *harnessOpts) error {
+		known := false
+		for _, s := range dcrdSubsystems {
+			if s == subsystem {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown dcrd subsystem %q (supported "+
+				"subsystems %v)", subsystem, dcrdSubsystems)
+		}
+		if level > slog.LevelOff {
+			return fmt.Errorf("invalid debug level %d", level)
+		}
+		if opts.subsystemLevels == nil {
+			opts.subsystemLevels = make(map[string]slog.Level)
+		}
+		opts.subsystemLevels[subsystem] = level
+		return nil
+	}
+}
+
+// checkListenAddr returns an error if the passed address is not a host:port
+// pair with a fixed port.
+func checkListenAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listening address %q: %w", addr, err)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || p == 0 {
+		return fmt.Errorf("listening address %q must have a fixed port",
+			addr)
+	}
+	return nil
+}
+
+// WithListenAddr sets the address the dcrd node listens on for P2P
+// connections (the --listen dcrd flag), instead of a loopback address chosen by
+// the harness. This allows tests that require a fixed port or a non-loopback
+// interface.
+//
+// The port must be fixed, since the harness does not discover the port chosen
+// by dcrd. When the host is unspecified (such as 0.0.0.0), the harness and
+// other harnesses connect to the node via the loopback address.
+func WithListenAddr(addr string) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if err := checkListenAddr(addr); err != nil {
+			return err
+		}
+		opts.listen = addr
+		return nil
+	}
+}
+
+// WithRPCListenAddr sets the address the dcrd node listens on for RPC
+// connections (the --rpclisten dcrd flag), instead of a loopback address
+// chosen by the harness.
+//
+// The same restrictions of WithListenAddr apply.
+func WithRPCListenAddr(addr string) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if err := checkListenAddr(addr); err != nil {
+			return err
+		}
+		opts.rpcListen = addr
+		return nil
+	}
+}
+
+// WithTxIndex sets whether the transaction index of the dcrd node (the
+// --txindex dcrd flag) is enabled. Disabling it allows tests to assert the
+// behavior of calls which require it, such as getrawtransaction for mined
+// transactions.
+//
+// By default, the transaction index is enabled.
+func WithTxIndex(enabled bool) HarnessOption {
+	return func(opts *harnessOpts) error {
+		opts.noTxIndex = !enabled
+		return nil
+	}
+}
+
+// WithExistsAddrIndex sets whether the exists address index of the dcrd node,
+// which tracks whether addresses have ever been used, is enabled (the inverse
+// of the --noexistsaddrindex dcrd flag). The index is queried with
+// Harness.ExistsAddress.
+//
+// Note that dcrd no longer provides the full address index (--addrindex) nor
+// searchrawtransactions, so the exists address index is the only address
+// index available.
+//
+// By default, the exists address index is enabled.
+func WithExistsAddrIndex(enabled bool) HarnessOption {
+	return func(opts *harnessOpts) error {
+		opts.noExistsAddrIndex = !enabled
+		return nil
+	}
+}
+
+// WithEnv sets environment variables for the dcrd process, such as GODEBUG or
+// GORACE. The variables are merged onto the environment of the current
+// process, replacing any variables with the same name.
+func WithEnv(env map[string]string) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if len(env) == 0 {
+			return errors.New("environment cannot be empty")
+		}
+		cp := make(map[string]string, len(env))
+		for key, value := range env {
+			if key == "" || strings.ContainsAny(key, "=\x00") {
+				return fmt.Errorf("invalid environment variable "+
+					"name %q", key)
+			}
+			cp[key] = value
+		}
+		opts.env = cp
+		return nil
+	}
+}
+
+// WithLogDumpOnFailure causes TearDown to write the contents of the dcrd log
+// file to the test log when the test has failed, before the node dir is
+// removed. See Harness.DumpLogs.
+func WithLogDumpOnFailure() HarnessOption {
+	return func(opts *harnessOpts) error {
+		opts.dumpLogsOnFailure = true
+		return nil
+	}
+}
+
+// WithFallbackFeeRate sets the fee rate, in atoms-per-byte, returned by
+// Harness.EstimateFeeRate when the node is unable to estimate fees, which is
+// the case until it has observed enough mined transactions. This also applies
+// to the transactions created by the harness with an estimated fee.
+//
+// By default, a fallback fee rate of 10 atoms-per-byte is used.
+func WithFallbackFeeRate(feeRate dcrutil.Amount) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if feeRate <= 0 {
+			return fmt.Errorf("fallback fee rate must be positive "+
+				"(got %d)", feeRate)
+		}
+		opts.fallbackFeeRate = feeRate
+		return nil
+	}
+}
+
+// WithProfilePort enables the HTTP profiling server of the dcrd node on the
+// passed port of the loopback interface (the --profile dcrd flag). Profiles
+// of the node may then be collected with Harness.FetchProfile.
+//
+// When the port is zero, a free port is chosen when the harness is created,
+// which is reported by Harness.ProfileAddress. Otherwise, dcrd requires the
+// port to be at least 1024.
+func WithProfilePort(port int) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if port != 0 && (port < 1024 || port > 65535) {
+			return fmt.Errorf("profile port must be zero or between "+
+				"1024 and 65535 (got %d)", port)
+		}
+		opts.profilePort = &port
+		return nil
+	}
+}
+
+// WithCertCurve sets the elliptic curve of the key of the TLS cert generated
+// for the RPC server of the dcrd node. Only the P-256, P-384 and P-521 curves
+// are supported.
+//
+// By default, the P-521 curve is used.
+func WithCertCurve(curve elliptic.Curve) HarnessOption {
+	return func(opts *harnessOpts) error {
+		switch curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		default:
+			return errors.New("cert curve must be one of P-256, " +
+				"P-384 or P-521")
+		}
+		opts.certCurve = curve
+		return nil
+	}
+}
+
+// WithCertValidity sets how long the TLS cert generated for the RPC server of
+// the dcrd node is valid for, starting from its creation. This also applies
+// to the certs created by Harness.RegenerateCert.
+//
+// By default, the cert is valid for 10 years.
+func WithCertValidity(validity time.Duration) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if validity <= 0 {
+			return fmt.Errorf("cert validity must be positive (got %v)",
+				validity)
+		}
+		opts.certValidity = validity
+		return nil
+	}
+}
+
+// WithTLSFiles makes the RPC server of the dcrd node use the provided TLS cert
+// and key files instead of generating a new pair. The files must exist and
+// form a valid pair, but the cert may otherwise be expired or not yet valid,
+// which allows testing how clients handle bad certs. Use WithStrictTLSFiles
+// to reject such certs instead.
+//
+// The files are not modified nor removed by the harness. The WithCertCurve
+// and WithCertValidity options have no effect on them.
+func WithTLSFiles(certFile, keyFile string) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if certFile == "" || keyFile == "" {
+			return errors.New("TLS cert and key files must be " +
+				"specified")
+		}
+		if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			return fmt.Errorf("invalid TLS cert pair %s and %s: %w",
+				certFile, keyFile, err)
+		}
+		opts.tlsCertFile = certFile
+		opts.tlsKeyFile = keyFile
+		return nil
+	}
+}
+
+// WithStrictTLSFiles makes New fail when the cert provided with WithTLSFiles
+// is expired or not yet valid.
+func WithStrictTLSFiles() HarnessOption {
+	return func(opts *harnessOpts) error {
+		opts.strictTLSFiles = true
+		return nil
+	}
+}
+
+// WithDisableTLS disables TLS for the RPC server of the dcrd node through its
+// --notls flag, and makes the RPC clients of the harness, including the config
+// returned by RPCConfig, connect without TLS.
+//
+// dcrd only allows disabling TLS when the RPC server listens on localhost,
+// thus this option may not be combined with WithRPCListenAddr using any other
+// host. It also may not be combined with RPC tracing or slow call reporting,
+// since the tracing proxy always uses TLS.
+func WithDisableTLS() HarnessOption {
+	return func(opts *harnessOpts) error {
+		opts.disableTLS = true
+		return nil
+	}
+}
+
+// WithRPCEndpoint sets the RPC endpoint of the dcrd node used by the config
+// returned by RPCConfig, and thus by the clients created from it, such as the
+// one returned by LimitedRPCClient. The "ws" endpoint, which is the default,
+// selects websockets, while the empty endpoint selects HTTP POST JSON-RPC
+// requests to the root path of the server, with the HTTPPostMode of the config
+// set. dcrd does not serve other endpoints.
+//
+// The RPC client of the harness itself always uses websockets, since it relies
+// on notifications, which are not available via HTTP POST.
+func WithRPCEndpoint(endpoint string) HarnessOption {
+	return func(opts *harnessOpts) error {
+		switch endpoint {
+		case wsEndpoint:
+			opts.httpPostMode = false
+		case httpPostEndpoint:
+			opts.httpPostMode = true
+		default:
+			return fmt.Errorf("unsupported RPC endpoint %q (must be "+
+				"%q or %q)", endpoint, wsEndpoint,
+				httpPostEndpoint)
+		}
+		return nil
+	}
+}
+
+// WithNoBanning disables the banning of misbehaving peers by the dcrd node (the
+// --nobanning dcrd flag), which is enabled by default. This allows P2P tests
+// to deliberately send invalid messages without being disconnected mid-test.
+//
+// This may not be combined with WithBanDuration nor WithBanThreshold, which
+// Validate reports.
+func WithNoBanning() HarnessOption {
+	return func(opts *harnessOpts) error {
+		opts.noBanning = true
+		return nil
+	}
+}
+
+// WithBanThreshold sets the ban score at which the dcrd node disconnects and
+// bans misbehaving peers (the --banthreshold dcrd flag), which otherwise
+// defaults to 100.
+func WithBanThreshold(threshold uint32) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if threshold == 0 {
+			return errors.New("ban threshold must be positive")
+		}
+		opts.banThreshold = threshold
+		return nil
+	}
+}
+
+// WithMaxPeers sets the max number of inbound and outbound peers of the dcrd
+// node (the --maxpeers dcrd flag), which otherwise defaults to 125.
+func WithMaxPeers(n int) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if n < 0 {
+			return fmt.Errorf("max number of peers must not be "+
+				"negative (got %d)", n)
+		}
+		opts.maxPeers = &n
+		return nil
+	}
+}
+
+// WithMaxSameIP sets the max number of peers of the dcrd node connecting from
+// the same IP (the --maxsameip dcrd flag), which otherwise defaults to 5.
+// Zero disables the limit. Since all harness nodes connect from the loopback
+// address, this limits the number of other harnesses a node accepts inbound
+// connections from.
+func WithMaxSameIP(n int) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if n < 0 {
+			return fmt.Errorf("max number of peers from the same IP "+
+				"must not be negative (got %d)", n)
+		}
+		opts.maxSameIP = &n
+		return nil
+	}
+}
+
+// WithRPCMaxClients sets the max number of standard (HTTP POST) RPC clients
+// the dcrd node serves at once (the --rpcmaxclients dcrd flag), which
+// otherwise defaults to 10. Further clients are rejected with a 503 status.
+func WithRPCMaxClients(n int) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if n < 1 {
+			return fmt.Errorf("max number of RPC clients must be at "+
+				"least 1 (got %d)", n)
+		}
+		opts.rpcMaxClients = n
+		return nil
+	}
+}
+
+// WithRPCMaxWebsockets sets the max number of websocket RPC clients the dcrd
+// node serves at once (the --rpcmaxwebsockets dcrd flag), which otherwise
+// defaults to 25. dcrd closes the connection of further clients right after
+// accepting it.
+//
+// The RPC client of the harness itself is a websocket client, as is the one of
+// each VotingWallet and the RPC tracing proxy, and they count towards the
+// limit.
+func WithRPCMaxWebsockets(n int) HarnessOption {
+	return func(opts *harnessOpts) error {
+		if n < 1 {
+			return fmt.Errorf("max number of RPC websockets must be "+
+				"at least 1 (got %d)", n)
+		}
+		opts.rpcMaxWebsockets = n
+		return nil
+	}
+}
+
+// WithTestDir makes the harness store the files of the dcrd node, such as its
+// data and log dirs, in the passed existing dir instead of a temp dir created
+// by the harness. This allows keeping the files of a node around, for example
+// for inspection by CI, or reusing the data dir of a previous run.
+//
+// The dir remains owned by the caller: Cleanup, which TearDown calls, only
+// removes the files and dirs the harness created in it, and never the dir
+// itself nor files and dirs that already existed. The files the harness writes
+// (rpc.cert, rpc.key, dcrd.conf and dcrd.pid) must not already exist, since
+// they would be overwritten.
+func WithTestDir(dir string) HarnessOption {
+	return func(opts *harnessOpts) error {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		fi, err := os.Stat(absDir)
+		if err != nil {
+			return fmt.Errorf("invalid test dir: %w", err)
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("test dir %s is not a dir", absDir)
+		}
+		opts.testDir = absDir
+		return nil
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/decred/dcrd/wire"
+)
+
+// p2pHandshakeTimeout is the max time to complete the P2P handshake with the
+// harness node when the context passed to DialP2P has no deadline.
+const p2pHandshakeTimeout = 10 * time.Second
+
+// setConnDeadline sets the deadline of the passed connection to the deadline
+// of the context, or to the passed default timeout from now if the context
+// does not have one.
+func setConnDeadline(ctx context.Context, conn net.Conn, timeout time.Duration) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(timeout)
+	}
+	return conn.SetDeadline(deadline)
+}
+
+// DialP2P opens a raw P2P connection to the harness node and performs the
+// version handshake, so that arbitrary messages may then be sent to the node
+// via SendRawP2PMessage. It returns the local address of the connection,
+// which is how the node identifies the peer (for example, in WaitForPeerBan).
+//
+// Only one raw P2P connection may be open at a time. It is closed by
+// CloseP2P or TearDown.
+func (h *Harness) DialP2P(ctx context.Context) (string, error) {
+	if h.rawPeer != nil {
+		return "", errors.New("raw P2P connection already open")
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", h.P2PAddress())
+	if err != nil {
+		return "", err
+	}
+	if err := h.p2pHandshake(ctx, conn); err != nil {
+		conn.Close()
+		return "", fmt.Errorf("P2P handshake failed: %w", err)
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return "", err
+	}
+	h.rawPeer = conn
+	return conn.LocalAddr().String(), nil
+}
+
+// p2pHandshake performs the version handshake with the harness node over the
+// passed connection.
+func (h *Harness) p2pHandshake(ctx context.Context, conn net.Conn) error {
+	if err := setConnDeadline(ctx, conn, p2pHandshakeTimeout); err != nil {
+		return err
+	}
+
+	pver, dcrnet := wire.ProtocolVersion, h.ActiveNet.Net
+	version, err := wire.NewMsgVersionFromConn(conn, rand.Uint64(), 0)
+	if err != nil {
+		return err
+	}
+	if err := wire.WriteMessage(conn, version, pver, dcrnet); err != nil {
+		return err
+	}
+
+	var gotVersion, gotVerAck bool
+	for !gotVersion || !gotVerAck {
+		msg, _, err := wire.ReadMessage(conn, pver, dcrnet)
+		if err != nil {
+			return err
+		}
+		switch msg.(type) {
+		case *wire.MsgVersion:
+			gotVersion = true
+			err := wire.WriteMessage(conn, wire.NewMsgVerAck(), pver, dcrnet)
+			if err != nil {
+				return err
+			}
+		case *wire.MsgVerAck:
+			gotVerAck = true
+		}
+	}
+	return nil
+}
+
+// SendRawP2PMessage sends the passed message to the harness node over the
+// raw P2P connection opened by DialP2P. Any message implementing
+// wire.Message may be sent, including custom implementations that encode
+// malformed payloads, which allows testing the validation of messages and
+// the banning of misbehaving peers by the node.
+func (h *Harness) SendRawP2PMessage(ctx context.Context, msg wire.Message) error {
+	if h.rawPeer == nil {
+		return errors.New("raw P2P connection is not open")
+	}
+	if err := setConnDeadline(ctx, h.rawPeer, p2pHandshakeTimeout); err != nil {
+		return err
+	}
+	return wire.WriteMessage(h.rawPeer, msg, wire.ProtocolVersion,
+		h.ActiveNet.Net)
+}
+
+// CloseP2P closes the raw P2P connection opened by DialP2P, if any.
+func (h *Harness) CloseP2P() error {
+	if h.rawPeer == nil {
+		return nil
+	}
+	err := h.rawPeer.Close()
+	h.rawPeer = nil
+	return err
+}
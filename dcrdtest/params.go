@@ -0,0 +1,53 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+// CoinbaseMaturity returns the number of blocks required before newly mined
+// coins (coinbase transactions) can be spent in the harness' network.
+func (h *Harness) CoinbaseMaturity() uint16 {
+	return h.ActiveNet.CoinbaseMaturity
+}
+
+// SStxChangeMaturity returns the number of blocks required before the change
+// outputs of ticket purchases can be spent in the harness' network.
+func (h *Harness) SStxChangeMaturity() uint16 {
+	return h.ActiveNet.SStxChangeMaturity
+}
+
+// TicketMaturity returns the number of blocks required before newly
+// purchased tickets become eligible to vote in the harness' network.
+func (h *Harness) TicketMaturity() uint16 {
+	return h.ActiveNet.TicketMaturity
+}
+
+// TicketExpiry returns the number of blocks after which a live ticket that
+// has not been selected to vote expires in the harness' network.
+func (h *Harness) TicketExpiry() uint32 {
+	return h.ActiveNet.TicketExpiry
+}
+
+// TicketPoolSize returns the target size of the ticket pool, expressed as a
+// multiple of TicketsPerBlock, of the harness' network.
+func (h *Harness) TicketPoolSize() uint16 {
+	return h.ActiveNet.TicketPoolSize
+}
+
+// TicketsPerBlock returns the number of tickets selected to vote on each
+// block of the harness' network.
+func (h *Harness) TicketsPerBlock() uint16 {
+	return h.ActiveNet.TicketsPerBlock
+}
+
+// StakeEnabledHeight returns the height at which tickets may start being
+// purchased in the harness' network.
+func (h *Harness) StakeEnabledHeight() int64 {
+	return h.ActiveNet.StakeEnabledHeight
+}
+
+// StakeValidationHeight returns the height from which blocks must include
+// votes in the harness' network.
+func (h *Harness) StakeValidationHeight() int64 {
+	return h.ActiveNet.StakeValidationHeight
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/wire"
+)
+
+// WaitForPeerBan waits until the harness' node bans and disconnects the peer
+// with the specified address due to misbehavior, as reported by the node's
+// log. peerAddr is matched against the start of the peer address as logged
+// by dcrd, so it may be either a host or a host:port pair.
+//
+// An error is returned if the peer is banned but none of the misbehaviors
+// logged for it contain reasonContains, or if the peer is not banned by the
+// time the context is done. In both cases, the error includes the logged
+// misbehavior reasons, if any.
+//
+// Only log lines output after the call are considered, therefore this must
+// be called (for example, in a separate goroutine) before the misbehavior is
+// triggered.
+func (h *Harness) WaitForPeerBan(ctx context.Context, peerAddr string, reasonContains string) error {
+	misbehavingPrefix := "Misbehaving peer " + peerAddr
+	banned := make(chan []string, 1)
+	var reasons []string
+	removeTap := h.node.addLogTap(func(line string) {
+		i := strings.Index(line, misbehavingPrefix)
+		if i < 0 {
+			return
+		}
+		msg := strings.TrimSpace(line[i+len(misbehavingPrefix):])
+		if strings.HasSuffix(msg, "-- banning and disconnecting") {
+			select {
+			case banned <- reasons:
+			default:
+			}
+			return
+		}
+
+		// Misbehavior lines are in the form "<addr> (<direction>):
+		// <reason> -- ban score ...".
+		if i := strings.Index(msg, ": "); i >= 0 {
+			msg = msg[i+2:]
+		}
+		if i := strings.LastIndex(msg, " -- ban score"); i >= 0 {
+			msg = msg[:i]
+		}
+		reasons = append(reasons, msg)
+	})
+	defer removeTap()
+
+	select {
+	case reasons := <-banned:
+		for _, reason := range reasons {
+			if strings.Contains(reason, reasonContains) {
+				return nil
+			}
+		}
+		return fmt.Errorf("peer %s was banned, but not for a reason "+
+			"containing %q (logged reasons: %q)", peerAddr,
+			reasonContains, reasons)
+	case <-ctx.Done():
+		h.node.logTapsMtx.Lock()
+		logged := reasons
+		h.node.logTapsMtx.Unlock()
+		return fmt.Errorf("peer %s was not banned (logged reasons: %q): %v",
+			peerAddr, logged, ctx.Err())
+	}
+}
+
+// p2pConn is a P2P connection from the node of one harness to another.
+type p2pConn struct {
+	from, to *Harness
+}
+
+// SetRelay controls whether the harness' node exchanges blocks and
+// transactions with the nodes of other active harnesses. This allows tests to
+// build controlled reorgs, where the blocks mined by one node stay local until
+// relay is enabled again.
+//
+// dcrd does not provide a setting to stop relaying blocks to connected peers,
+// therefore disabling relay is done by removing every connection between the
+// node and the nodes of other active harnesses, in both directions.
+// Re-enabling relay restores the removed connections. Connections to peers
+// that are not harness nodes are not affected.
+//
+// NOTE: This method should not be called concurrently with other methods
+// that change the connections of the involved harnesses.
+func (h *Harness) SetRelay(ctx context.Context, enabled bool) error {
+	if enabled {
+		var err error
+		h.removedConns, err = restoreConns(ctx, h.removedConns)
+		return err
+	}
+
+	for _, other := range ActiveHarnesses() {
+		if other == h || other.Node == nil {
+			continue
+		}
+		removed, err := disconnectNodes(ctx, h, other)
+		h.removedConns = append(h.removedConns, removed...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// disconnectNodes removes the connections between the nodes of the passed
+// harnesses in every direction they are connected and returns the removed
+// connections, so that they may be restored with restoreConns. On errors, the
+// connections removed before the failure are also returned.
+func disconnectNodes(ctx context.Context, a, b *Harness) ([]p2pConn, error) {
+	var removed []p2pConn
+	for _, conn := range []p2pConn{{a, b}, {b, a}} {
+		connected, err := NodesConnected(ctx, conn.from, conn.to, false)
+		if err != nil {
+			return removed, err
+		}
+		if !connected {
+			continue
+		}
+		if err := RemoveNode(ctx, conn.from, conn.to); err != nil {
+			return removed, fmt.Errorf("unable to remove connection "+
+				"from %s to %s: %w", conn.from.P2PAddress(),
+				conn.to.P2PAddress(), err)
+		}
+		removed = append(removed, conn)
+	}
+	return removed, nil
+}
+
+// restoreConns establishes the passed connections, in order. On errors, the
+// connections that were not restored are returned along with the error.
+func restoreConns(ctx context.Context, conns []p2pConn) ([]p2pConn, error) {
+	for len(conns) > 0 {
+		conn := conns[0]
+		if err := ConnectNode(ctx, conn.from, conn.to); err != nil {
+			return conns, fmt.Errorf("unable to restore connection "+
+				"from %s to %s: %w", conn.from.P2PAddress(),
+				conn.to.P2PAddress(), err)
+		}
+		conns = conns[1:]
+	}
+	return nil, nil
+}
+
+// LocalServices returns the service flags advertised by the harness' node to
+// its peers, as reported by the getnetworkinfo RPC.
+func (h *Harness) LocalServices(ctx context.Context) (wire.ServiceFlag, error) {
+	info, err := h.Node.GetNetworkInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	services, err := strconv.ParseUint(info.LocalServices, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to decode local services %q: %w",
+			info.LocalServices, err)
+	}
+	return wire.ServiceFlag(services), nil
+}
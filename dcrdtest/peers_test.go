@@ -0,0 +1,94 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWaitForPeerBan ensures WaitForPeerBan correctly parses the ban related
+// lines logged by dcrd.
+func TestWaitForPeerBan(t *testing.T) {
+	const (
+		peerAddr  = "127.0.0.1:19555"
+		logPrefix = "2022-10-04 10:00:00.000 [WRN] PEER: "
+	)
+	tests := []struct {
+		name    string
+		lines   []string
+		reason  string
+		wantErr string
+	}{{
+		name: "banned for expected reason",
+		lines: []string{
+			logPrefix + "Misbehaving peer 127.0.0.1:19001 (inbound): " +
+				"other peer -- ban score increased to 60",
+			logPrefix + "Misbehaving peer " + peerAddr + " (inbound): " +
+				"sent an invalid block -- ban score increased to 101",
+			logPrefix + "Misbehaving peer " + peerAddr + " (inbound) " +
+				"-- banning and disconnecting",
+		},
+		reason: "invalid block",
+	}, {
+		name: "banned for another reason",
+		lines: []string{
+			logPrefix + "Misbehaving peer " + peerAddr + " (inbound): " +
+				"sent too many addresses -- ban score increased to 101",
+			logPrefix + "Misbehaving peer " + peerAddr + " (inbound) " +
+				"-- banning and disconnecting",
+		},
+		reason:  "invalid block",
+		wantErr: `["sent too many addresses"]`,
+	}, {
+		name: "other peer banned",
+		lines: []string{
+			logPrefix + "Misbehaving peer 127.0.0.1:19001 (inbound): " +
+				"sent an invalid block -- ban score increased to 101",
+			logPrefix + "Misbehaving peer 127.0.0.1:19001 (inbound) " +
+				"-- banning and disconnecting",
+		},
+		reason:  "invalid block",
+		wantErr: "was not banned",
+	}}
+
+	for _, test := range tests {
+		h := &Harness{node: &node{}}
+		ctx, cancel := context.WithTimeout(context.Background(),
+			100*time.Millisecond)
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- h.WaitForPeerBan(ctx, peerAddr, test.reason)
+		}()
+
+		// Wait until the log tap is registered.
+		for {
+			h.node.logTapsMtx.Lock()
+			n := len(h.node.logTaps)
+			h.node.logTapsMtx.Unlock()
+			if n > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		for _, line := range test.lines {
+			h.node.forwardLine([]byte(line + "\n"))
+		}
+
+		err := <-errChan
+		cancel()
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		case test.wantErr != "" && err == nil:
+			t.Fatalf("%s: expected an error", test.name)
+		case err != nil && !strings.Contains(err.Error(), test.wantErr):
+			t.Fatalf("%s: got error %q, want it to contain %q",
+				test.name, err, test.wantErr)
+		}
+	}
+}
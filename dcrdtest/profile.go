@@ -0,0 +1,69 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// freeLocalPort returns a TCP port of the loopback interface which is free at
+// the time of the call.
+func freeLocalPort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// ProfileAddress returns the address of the HTTP profiling server of the
+// harness' node enabled with WithProfilePort, or an empty string when it is
+// not enabled.
+func (h *Harness) ProfileAddress() string {
+	return h.node.config.profile
+}
+
+// FetchProfile returns the profile of the passed kind, such as "heap",
+// "goroutine" or "profile?seconds=5" (a CPU profile), collected by the HTTP
+// profiling server of the harness' node. The kinds are the ones served by
+// net/http/pprof under /debug/pprof/.
+//
+// The profiling server must have been enabled with WithProfilePort.
+func (h *Harness) FetchProfile(ctx context.Context, kind string) ([]byte, error) {
+	addr := h.ProfileAddress()
+	if addr == "" {
+		return nil, errors.New("profiling server is not enabled")
+	}
+	if kind == "" || strings.HasPrefix(kind, "/") {
+		return nil, fmt.Errorf("invalid profile kind %q", kind)
+	}
+
+	url := fmt.Sprintf("http://%s/debug/pprof/%s", addr, kind)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch %s profile: %s: %s", kind,
+			resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
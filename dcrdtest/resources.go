@@ -0,0 +1,103 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// procClockTicks is the number of clock ticks per second used for the CPU
+// times reported in /proc, which is fixed by the Linux ABI.
+const procClockTicks = 100
+
+// ResourceUsage is a sample of the resources used by a dcrd process.
+type ResourceUsage struct {
+	// RSS is the resident set size of the process in bytes.
+	RSS uint64
+
+	// CPUTime is the total user and system CPU time consumed by the
+	// process since it was launched.
+	CPUTime time.Duration
+}
+
+// Pid returns the process ID of the dcrd process of the harness, or zero if
+// the process is not running.
+func (h *Harness) Pid() int {
+	if !h.node.running {
+		return 0
+	}
+	return h.node.pid
+}
+
+// ResourceUsage samples the resources currently used by the dcrd process of
+// the harness. Calling it periodically during a workload allows asserting the
+// memory and CPU used by dcrd stays under a threshold.
+//
+// This is only supported on Linux, where the usage is read from /proc.
+func (h *Harness) ResourceUsage() (*ResourceUsage, error) {
+	pid := h.Pid()
+	if pid == 0 {
+		return nil, errors.New("dcrd process is not running")
+	}
+	return procResourceUsage(pid)
+}
+
+// procResourceUsage reads the resources used by the process with the passed
+// ID from /proc.
+func procResourceUsage(pid int) (*ResourceUsage, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("resource usage is not supported on %s",
+			runtime.GOOS)
+	}
+
+	path := filepath.Join("/proc", strconv.Itoa(pid), "stat")
+	stat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := parseProcStat(string(stat))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	return usage, nil
+}
+
+// parseProcStat parses the resource usage from the contents of a
+// /proc/[pid]/stat file.
+func parseProcStat(stat string) (*ResourceUsage, error) {
+	// The command name (second field) is enclosed in parenthesis and may
+	// contain spaces, so the remaining fields are split after it. The
+	// first remaining field is the process state (third field).
+	end := strings.LastIndexByte(stat, ')')
+	if end == -1 {
+		return nil, errors.New("missing command name")
+	}
+	fields := strings.Fields(stat[end+1:])
+	const utimeIdx, stimeIdx, rssIdx = 14 - 3, 15 - 3, 24 - 3
+	if len(fields) <= rssIdx {
+		return nil, fmt.Errorf("too few fields (%d)", len(fields)+2)
+	}
+
+	var values [3]uint64
+	for i, idx := range []int{utimeIdx, stimeIdx, rssIdx} {
+		v, err := strconv.ParseUint(fields[idx], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	ticks := values[0] + values[1]
+	return &ResourceUsage{
+		RSS:     values[2] * uint64(os.Getpagesize()),
+		CPUTime: time.Duration(ticks) * time.Second / procClockTicks,
+	}, nil
+}
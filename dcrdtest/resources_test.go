@@ -0,0 +1,49 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestParseProcStat ensures the resource usage is parsed from a /proc stat
+// file, including when the command name contains spaces and parenthesis.
+func TestParseProcStat(t *testing.T) {
+	const stat = "4242 (dcrd (test) x) S 1 4242 4242 0 -1 4194560 2573 0 0 " +
+		"0 150 50 0 0 20 0 12 0 100 123456789 2048 18446744073709551615"
+	usage, err := parseProcStat(stat)
+	if err != nil {
+		t.Fatalf("unable to parse stat: %v", err)
+	}
+	if want := 2 * time.Second; usage.CPUTime != want {
+		t.Fatalf("unexpected CPU time: got %v, want %v", usage.CPUTime,
+			want)
+	}
+	if want := uint64(2048 * os.Getpagesize()); usage.RSS != want {
+		t.Fatalf("unexpected RSS: got %d, want %d", usage.RSS, want)
+	}
+
+	if _, err := parseProcStat("4242 (dcrd) S 1 4242"); err == nil {
+		t.Fatal("expected an error for a truncated stat")
+	}
+}
+
+// TestProcResourceUsage ensures the resource usage of the current process can
+// be read.
+func TestProcResourceUsage(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resource usage is only supported on linux")
+	}
+	usage, err := procResourceUsage(os.Getpid())
+	if err != nil {
+		t.Fatalf("unable to read resource usage: %v", err)
+	}
+	if usage.RSS == 0 {
+		t.Fatal("unexpected zero RSS")
+	}
+}
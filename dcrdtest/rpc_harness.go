@@ -7,24 +7,44 @@ package dcrdtest
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/chaincfg/v3"
+	"github.com/decred/dcrd/dcrjson/v4"
 	"github.com/decred/dcrd/dcrutil/v4"
+	dcrdtypes "github.com/decred/dcrd/rpc/jsonrpc/types/v4"
 	"github.com/decred/dcrd/rpcclient/v8"
 	"github.com/decred/dcrd/txscript/v4/stdaddr"
 	"github.com/decred/dcrd/wire"
 )
 
 const (
+	// fundAddressFeeRate is the fee rate, in atoms per byte, used by
+	// FundAddress.
+	fundAddressFeeRate = dcrutil.Amount(1e4)
+
+	// faucetFeeMargin is the amount on top of the requested one that the
+	// internal wallet must have available before Faucet funds an address,
+	// which covers the fee of funding transactions of up to 10 KB.
+	faucetFeeMargin = fundAddressFeeRate * 10000
+
+	// defaultFallbackFeeRate is the fee rate, in atoms per byte, used by
+	// EstimateFeeRate when the node is unable to estimate fees, unless
+	// overridden with WithFallbackFeeRate.
+	defaultFallbackFeeRate = dcrutil.Amount(10)
+
 	// These constants define the minimum and maximum p2p and rpc port
 	// numbers used by a test harness.  The min port is inclusive while the
 	// max port is exclusive.
@@ -85,15 +105,46 @@ type Harness struct {
 	// to.
 	ActiveNet *chaincfg.Params
 
+	// Node is the RPC client connected to the dcrd node of the harness.
+	// Client should be preferred in order to not depend on the version of
+	// the RPC client.
 	Node     *rpcclient.Client
 	node     *node
 	handlers *rpcclient.NotificationHandlers
 
 	wallet *memWallet
 
-	testNodeDir    string
-	maxConnRetries int
-	nodeNum        int
+	opts   harnessOpts
+	tracer *rpcTracer
+
+	// rawPeer is the raw P2P connection opened by DialP2P.
+	rawPeer net.Conn
+
+	// removedConns are the P2P connections removed by SetRelay in order
+	// to disable relaying, which are restored once it is enabled again.
+	removedConns []p2pConn
+
+	// miningMtx serializes the mining requests made by the harness.
+	miningMtx sync.Mutex
+
+	// blockNtfns are the channels returned by NotifyBlocks.
+	blockNtfnsMtx sync.Mutex
+	blockNtfns    []chan *BlockConnected
+
+	// txNtfns are the channels returned by NotifyNewTransactions and
+	// txNtfnsVerbose whether they receive verbose notifications.
+	txNtfnsMtx     sync.Mutex
+	txNtfns        []chan *TxAccepted
+	txNtfnsVerbose bool
+
+	// testNodeDir is the dir holding the files of the node. It is removed
+	// on cleanup when ownsTestDir is set, otherwise only the createdPaths
+	// in it are.
+	testNodeDir  string
+	ownsTestDir  bool
+	createdPaths []string
+
+	nodeNum int
 
 	t *testing.T
 
@@ -122,13 +173,23 @@ func SetPathToDCRD(fnScopePathToDCRD string) {
 // pathToDCRD has already been set, the executable at that location will be
 // used.
 //
+// Additional functional options may be passed to further customize the
+// harness.
+//
 // NOTE: This function is safe for concurrent access, but care must be taken
 // when calling New with different dcrd executables, as whatever is at
 // pathToDCRD at the time will be used to launch that node.
-func New(t *testing.T, activeNet *chaincfg.Params, handlers *rpcclient.NotificationHandlers, extraArgs []string) (*Harness, error) {
+func New(t *testing.T, activeNet *chaincfg.Params, handlers *rpcclient.NotificationHandlers, extraArgs []string, opts ...HarnessOption) (*Harness, error) {
 	harnessStateMtx.Lock()
 	defer harnessStateMtx.Unlock()
 
+	var hopts harnessOpts
+	for _, opt := range opts {
+		if err := opt(&hopts); err != nil {
+			return nil, fmt.Errorf("invalid harness option: %w", err)
+		}
+	}
+
 	// Add a flag for the appropriate network type based on the provided
 	// chain params.
 	switch activeNet.Net {
@@ -145,43 +206,102 @@ func New(t *testing.T, activeNet *chaincfg.Params, handlers *rpcclient.Notificat
 			"of the supported chain networks")
 	}
 
-	harnessID := strconv.Itoa(numTestInstances)
-	nodeTestData, err := os.MkdirTemp("", "dcrdtest-"+harnessID)
-	if err != nil {
-		return nil, err
+	// Use the caller provided test dir, only removing the files created in
+	// it on cleanup, or create a temp dir which is entirely removed.
+	nodeTestData := hopts.testDir
+	var createdPaths []string
+	var err error
+	if nodeTestData != "" {
+		if _, active := testInstances[nodeTestData]; active {
+			return nil, fmt.Errorf("test dir %s is in use by another "+
+				"harness", nodeTestData)
+		}
+		createdPaths, err = testDirPaths(nodeTestData,
+			hopts.tlsCertFile == "")
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		harnessID := strconv.Itoa(numTestInstances)
+		nodeTestData, err = os.MkdirTemp("", "dcrdtest-"+harnessID)
+		if err != nil {
+			return nil, err
+		}
 	}
-	debugf(t, "temp dir: %v\n", nodeTestData)
+	debugf(t, "test dir: %v\n", nodeTestData)
 
-	certFile := filepath.Join(nodeTestData, "rpc.cert")
-	keyFile := filepath.Join(nodeTestData, "rpc.key")
-	if err := genCertPair(certFile, keyFile); err != nil {
-		return nil, err
+	certFile, keyFile := hopts.tlsCertFile, hopts.tlsKeyFile
+	if certFile == "" {
+		certFile = filepath.Join(nodeTestData, "rpc.cert")
+		keyFile = filepath.Join(nodeTestData, "rpc.key")
+		err = genCertPair(certFile, keyFile, hopts.certCurve,
+			hopts.certValidity)
+		if err != nil {
+			return nil, err
+		}
+	} else if hopts.strictTLSFiles {
+		if err := checkCertValidity(certFile, time.Now()); err != nil {
+			return nil, err
+		}
 	}
 
-	wallet, err := newMemWallet(t, activeNet, uint32(numTestInstances))
+	wallet, err := newMemWallet(t, activeNet, uint32(numTestInstances),
+		hopts.hdSeed)
 	if err != nil {
 		return nil, err
 	}
 
 	miningAddr := fmt.Sprintf("--miningaddr=%s", wallet.coinbaseAddr)
 	extraArgs = append(extraArgs, miningAddr)
+	for _, addr := range hopts.miningAddrs {
+		_, err := stdaddr.DecodeAddress(addr.String(), activeNet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mining address %s for "+
+				"network %s: %w", addr, activeNet.Name, err)
+		}
+		extraArgs = append(extraArgs, fmt.Sprintf("--miningaddr=%s", addr))
+	}
 
 	config, err := newConfig(nodeTestData, certFile, keyFile, extraArgs)
 	if err != nil {
 		return nil, err
 	}
 
+	// Generate p2p+rpc listening addresses, which may be overridden by
+	// the options.
+	config.listen, config.rpcListen = generateListeningAddresses()
+	if hopts.profilePort != nil && *hopts.profilePort == 0 {
+		port, err := freeLocalPort()
+		if err != nil {
+			return nil, fmt.Errorf("unable to choose profile port: %w",
+				err)
+		}
+		hopts.profilePort = &port
+	}
+	hopts.applyNodeConfig(config)
+	if err := config.writeConfigFile(); err != nil {
+		return nil, fmt.Errorf("unable to write config file: %w", err)
+	}
+	if hopts.seedDataDir != "" {
+		err := seedDataDir(hopts.seedDataDir, config.dataDir, activeNet)
+		if err != nil {
+			return nil, fmt.Errorf("unable to seed data dir: %w", err)
+		}
+	}
+
 	// Uncomment and change to enable additional dcrd debug/trace output.
 	// config.debugLevel = "TXMP=trace,TRSY=trace,RPCS=trace,PEER=trace"
 
-	// Generate p2p+rpc listening addresses.
-	config.listen, config.rpcListen = generateListeningAddresses()
-
 	// Create the testing node bounded to the simnet.
 	node, err := newNode(t, config, nodeTestData)
 	if err != nil {
 		return nil, err
 	}
+	if hopts.logSink != nil {
+		node.logSink = &syncWriter{w: hopts.logSink}
+	}
+	node.onEarlyExit = hopts.onEarlyExit
+	node.stopTimeout = hopts.stopTimeout
 	nodeNum := numTestInstances
 	numTestInstances++ // XXX this really should be the length of the harness map.
 
@@ -214,14 +334,46 @@ func New(t *testing.T, activeNet *chaincfg.Params, handlers *rpcclient.Notificat
 	}
 
 	h := &Harness{
-		handlers:       handlers,
-		node:           node,
-		maxConnRetries: 20,
-		testNodeDir:    nodeTestData,
-		ActiveNet:      activeNet,
-		nodeNum:        nodeNum,
-		wallet:         wallet,
-		t:              t,
+		handlers:     handlers,
+		node:         node,
+		testNodeDir:  nodeTestData,
+		ownsTestDir:  hopts.testDir == "",
+		createdPaths: createdPaths,
+		ActiveNet:    activeNet,
+		nodeNum:      nodeNum,
+		wallet:       wallet,
+		opts:         hopts,
+		t:            t,
+	}
+
+	// Fan out the connected blocks to the channels returned by
+	// NotifyBlocks.
+	obc := handlers.OnBlockConnected
+	handlers.OnBlockConnected = func(header []byte, filteredTxns [][]byte) {
+		obc(header, filteredTxns)
+		h.notifyBlockConnected(header, filteredTxns)
+	}
+
+	// Fan out the accepted transactions to the channels returned by
+	// NotifyNewTransactions.
+	ota := handlers.OnTxAccepted
+	handlers.OnTxAccepted = func(hash *chainhash.Hash, amount dcrutil.Amount) {
+		if ota != nil {
+			ota(hash, amount)
+		}
+		h.notifyTxAccepted(&TxAccepted{Hash: *hash, Amount: amount})
+	}
+	otav := handlers.OnTxAcceptedVerbose
+	handlers.OnTxAcceptedVerbose = func(details *dcrdtypes.TxRawResult) {
+		if otav != nil {
+			otav(details)
+		}
+		hash, err := chainhash.NewHashFromStr(details.Txid)
+		if err != nil {
+			logf(h.t, "unable to decode accepted tx hash: %v", err)
+			return
+		}
+		h.notifyTxAccepted(&TxAccepted{Hash: *hash, Details: details})
 	}
 
 	// Track this newly created test instance within the package level
@@ -239,12 +391,31 @@ func New(t *testing.T, activeNet *chaincfg.Params, handlers *rpcclient.Notificat
 // NOTE: This method and TearDown should always be called from the same
 // goroutine as they are not concurrent safe.
 func (h *Harness) SetUp(ctx context.Context, createTestChain bool, numMatureOutputs uint32) error {
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("invalid harness config:\n%w", err)
+	}
+
 	// Start the dcrd node itself. This spawns a new process which will be
 	// managed
 	if err := h.node.start(); err != nil {
 		return err
 	}
-	if err := h.connectRPCClient(); err != nil {
+	if h.opts.rpcTraceWriter != nil || h.opts.slowRPCThreshold > 0 {
+		cfg := h.node.config
+		tracer, err := newRPCTracer(h.opts.rpcTraceWriter,
+			dialAddr(cfg.rpcListen),
+			cfg.certificates, cfg.certFile, cfg.keyFile)
+		if err != nil {
+			return fmt.Errorf("unable to start RPC tracer: %w", err)
+		}
+		tracer.slowThreshold = h.opts.slowRPCThreshold
+		tracer.warnf = func(format string, args ...interface{}) {
+			logf(h.t, "[WRN] "+format, args...)
+		}
+		tracer.start()
+		h.tracer = tracer
+	}
+	if err := h.connectRPCClient(ctx); err != nil {
 		return err
 	}
 	h.wallet.Start()
@@ -275,6 +446,21 @@ func (h *Harness) SetUp(ctx context.Context, createTestChain bool, numMatureOutp
 		if err != nil {
 			return err
 		}
+
+		// When there are additional mining addresses, not every block
+		// pays to the wallet, so keep mining until it has the desired
+		// number of mature outputs.
+		for len(h.opts.miningAddrs) > 0 {
+			if err := h.waitWalletSynced(ctx); err != nil {
+				return err
+			}
+			if h.wallet.numMatureOutputs() >= int(numMatureOutputs) {
+				break
+			}
+			if _, err := h.Node.Generate(ctx, 1); err != nil {
+				return err
+			}
+		}
 	}
 
 	// Block until the wallet has fully synced up to the tip of the main
@@ -284,20 +470,31 @@ func (h *Harness) SetUp(ctx context.Context, createTestChain bool, numMatureOutp
 		return err
 	}
 	tracef(h.t, "Best block height: %v", height)
-	ticker := time.NewTicker(time.Millisecond * 100)
-	for range ticker.C {
-		walletHeight := h.wallet.SyncedHeight()
-		if walletHeight == height {
-			break
-		}
+	err = pollUntil(ctx, func() (bool, error) {
+		return h.wallet.SyncedHeight() == height, nil
+	}, func(err error) error {
+		return fmt.Errorf("wallet did not sync to height %d: %w", height,
+			err)
+	})
+	if err != nil {
+		return err
 	}
 	tracef(h.t, "Synced: %v", height)
 
+	if h.opts.generateThreads > 0 {
+		tracef(h.t, "SetGenerate: %d threads", h.opts.generateThreads)
+		err := h.Node.SetGenerate(ctx, true, h.opts.generateThreads)
+		if err != nil {
+			return fmt.Errorf("unable to enable CPU mining: %w", err)
+		}
+	}
+
 	return nil
 }
 
 // TearDown stops the running rpc test instance. All created processes are
-// killed, and temporary directories removed.
+// killed, and the files of the node removed via Cleanup, unless debug or trace
+// logging is enabled.
 //
 // NOTE: This method and SetUp should always be called from the same goroutine
 // as they are not concurrent safe.
@@ -308,6 +505,22 @@ func (h *Harness) TearDown() error {
 	if h.Node != nil {
 		tracef(h.t, "TearDown: Node")
 		h.Node.Shutdown()
+		h.Node.WaitForShutdown()
+	}
+	h.wallet.Stop()
+	h.closeBlockNtfns()
+	h.closeTxNtfns()
+
+	if err := h.CloseP2P(); err != nil {
+		logf(h.t, "unable to close raw P2P connection: %v", err)
+	}
+
+	if h.tracer != nil {
+		tracef(h.t, "TearDown: tracer")
+		if err := h.tracer.stop(); err != nil {
+			return err
+		}
+		h.tracer = nil
 	}
 
 	tracef(h.t, "TearDown: node")
@@ -315,8 +528,17 @@ func (h *Harness) TearDown() error {
 		return err
 	}
 
+	if h.opts.dumpLogsOnFailure && h.t != nil && h.t.Failed() {
+		var b strings.Builder
+		if err := h.DumpLogs(&b); err != nil {
+			logf(h.t, "unable to dump dcrd log: %v", err)
+		} else {
+			logf(h.t, "dcrd log:\n%s", b.String())
+		}
+	}
+
 	if !(debug || trace) {
-		if err := os.RemoveAll(h.testNodeDir); err != nil {
+		if err := h.Cleanup(); err != nil {
 			return err
 		}
 	}
@@ -327,34 +549,401 @@ func (h *Harness) TearDown() error {
 	return nil
 }
 
+// Cleanup removes the files and dirs of the harness' node, such as its data and
+// log dirs, which must be stopped. It is called by TearDown, unless debug or
+// trace logging is enabled, in which case the files are kept for inspection
+// and Cleanup may be called once done with them.
+//
+// The ownership of the files follows who created the test dir holding them:
+// the temp dir created by the harness by default is removed entirely, while
+// from a dir provided with WithTestDir, only the files and dirs created by the
+// harness are removed, and the dir itself and anything that already existed in
+// it are kept.
+func (h *Harness) Cleanup() error {
+	if h.node.running {
+		return errors.New("unable to clean up the files of a running node")
+	}
+	if h.ownsTestDir {
+		return os.RemoveAll(h.testNodeDir)
+	}
+	for _, path := range h.createdPaths {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// testDirPaths returns the paths of the files and dirs the harness creates in
+// the passed caller provided test dir which do not exist yet, thus which are
+// owned by the harness. An error is returned if the files the harness writes
+// already exist, which includes the TLS cert pair when it is generated.
+func testDirPaths(dir string, genCert bool) ([]string, error) {
+	files := []string{"dcrd.conf", "dcrd.pid"}
+	if genCert {
+		files = append(files, "rpc.cert", "rpc.key")
+	}
+	var paths []string
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		_, err := os.Lstat(path)
+		if err == nil {
+			return nil, fmt.Errorf("%s already exists in the test dir",
+				path)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	for _, name := range []string{"data", "logs"} {
+		path := filepath.Join(dir, name)
+		_, err := os.Lstat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			paths = append(paths, path)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// DumpLogs writes the contents of the log file of the harness' dcrd node to w.
+// Unlike the output forwarded to the test log, the log file includes the
+// whole log of the node, from every launch of its process.
+//
+// The log file is removed by TearDown, so this must be called before it.
+// WithLogDumpOnFailure may be used to dump the log on TearDown instead.
+func (h *Harness) DumpLogs(w io.Writer) error {
+	f, err := os.Open(h.logFilePath())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// logFilePath returns the path of the log file of the harness' dcrd node,
+// which dcrd places in a network specific subdir of the log dir.
+func (h *Harness) logFilePath() string {
+	return filepath.Join(h.node.config.logDir, h.ActiveNet.Name, "dcrd.log")
+}
+
+// GuardPanic runs f and, if it panics, tears down the harness before
+// re-panicking with the same value. This guarantees the dcrd process is not
+// leaked when test code panics while the harness is live, regardless of how
+// the test defers the call to TearDown. It is an optional safety wrapper for
+// risky test bodies:
+//
+//	h.GuardPanic(func() {
+//		// Test code which may panic.
+//	})
+//
+// Note that t.Fatal and similar functions do not panic, therefore they do not
+// trigger the tear down.
+func (h *Harness) GuardPanic(f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			if err := h.TearDown(); err != nil {
+				logf(h.t, "unable to tear down harness after panic: %v",
+					err)
+			}
+			panic(r)
+		}
+	}()
+	f()
+}
+
+// Restart stops the dcrd process of the harness and starts it again with the
+// same config, preserving its data directory. This allows tests to verify
+// the state persisted by the node across restarts. The RPC client is
+// reconnected to the new process and the notifications required by the
+// internal wallet are re-registered. If the harness was created with
+// WithGenerateThreads, the CPU miner is enabled again.
+//
+// Given dcrd does not persist its mempool, the mempool of the restarted node
+// is empty. Likewise, the P2P connections of the node are dropped.
+//
+// NOTE: This method should not be called concurrently with other methods of
+// the harness.
+func (h *Harness) Restart(ctx context.Context) error {
+	tracef(h.t, "Restart")
+	defer tracef(h.t, "Restart done")
+
+	if err := h.stopNode(); err != nil {
+		return err
+	}
+	if err := h.startNode(ctx); err != nil {
+		return err
+	}
+	if h.opts.generateThreads > 0 {
+		err := h.Node.SetGenerate(ctx, true, h.opts.generateThreads)
+		if err != nil {
+			return fmt.Errorf("unable to enable CPU mining: %w", err)
+		}
+	}
+	return nil
+}
+
+// RegenerateCert replaces the TLS cert and key of the RPC server of the
+// harness' node with a freshly generated pair, using the curve and validity set
+// with WithCertCurve and WithCertValidity, and restarts the node via Restart
+// so that dcrd loads them. The RPC clients created by the harness, including
+// the config returned by RPCConfig, trust the new cert afterwards, which allows
+// testing how clients handle a rotated cert.
+//
+// This is not supported when RPC tracing or slow call reporting is enabled,
+// since the tracing proxy keeps using the original cert, nor when the cert was
+// provided with WithTLSFiles.
+//
+// NOTE: This method should not be called concurrently with other methods of
+// the harness.
+func (h *Harness) RegenerateCert(ctx context.Context) error {
+	if h.tracer != nil {
+		return errors.New("cert regeneration is not supported with " +
+			"RPC tracing")
+	}
+	if h.opts.tlsCertFile != "" {
+		return errors.New("cert regeneration is not supported with " +
+			"TLS files provided by WithTLSFiles")
+	}
+
+	cfg := h.node.config
+	err := genCertPair(cfg.certFile, cfg.keyFile, h.opts.certCurve,
+		h.opts.certValidity)
+	if err != nil {
+		return fmt.Errorf("unable to generate cert pair: %w", err)
+	}
+	cert, err := os.ReadFile(cfg.certFile)
+	if err != nil {
+		return err
+	}
+	cfg.certificates = cert
+	return h.Restart(ctx)
+}
+
+// stopNode disconnects the RPC client of the harness and stops its dcrd
+// process, so that it may be started again via startNode.
+func (h *Harness) stopNode() error {
+	if h.Node != nil {
+		h.Node.Shutdown()
+		h.Node.WaitForShutdown()
+		h.Node = nil
+	}
+	return h.node.stop()
+}
+
+// startNode starts the dcrd process of the harness after it was stopped via
+// stopNode, connects the RPC client to it and re-registers the notifications
+// required by the internal wallet.
+func (h *Harness) startNode(ctx context.Context) error {
+	// A process can only be started once, so prepare a new command to
+	// launch the node again.
+	h.node.cmd = h.node.config.command()
+	if err := h.node.start(); err != nil {
+		return err
+	}
+	if err := h.connectRPCClient(ctx); err != nil {
+		return err
+	}
+	if err := h.Node.LoadTxFilter(ctx, true, h.wallet.addresses(), nil); err != nil {
+		return err
+	}
+	if err := h.Node.NotifyBlocks(ctx); err != nil {
+		return err
+	}
+	return h.reregisterTxNtfns(ctx)
+}
+
+// waitWalletSynced blocks until the internal wallet has processed every block
+// up to the current tip of the node or the context is done.
+func (h *Harness) waitWalletSynced(ctx context.Context) error {
+	_, height, err := h.Node.GetBestBlock(ctx)
+	if err != nil {
+		return err
+	}
+	return pollUntil(ctx, func() (bool, error) {
+		return h.wallet.SyncedHeight() == height, nil
+	}, func(err error) error {
+		return err
+	})
+}
+
+// ResetChain returns the chain of the harness node to the genesis block, as
+// if the harness had just been set up without creating a test chain. This is
+// done by stopping the dcrd process, wiping its data directory and starting it
+// again, which is considerably faster than tearing down the harness and
+// setting up a new one, since the harness resources (such as the TLS cert
+// pair and listening addresses) are reused. This makes it suitable for
+// benchmarks that require a fresh chain on each iteration.
+//
+// The internal wallet is reset along with the chain, so it has no spendable
+// outputs until new blocks are mined. If the harness was created with
+// WithGenerateThreads, the CPU miner is stopped during the reset and enabled
+// again afterwards.
+//
+// NOTE: This method should not be called concurrently with other methods of
+// the harness.
+func (h *Harness) ResetChain(ctx context.Context) error {
+	tracef(h.t, "ResetChain")
+	defer tracef(h.t, "ResetChain done")
+
+	if h.opts.generateThreads > 0 {
+		if err := h.Node.SetGenerate(ctx, false, 0); err != nil {
+			return fmt.Errorf("unable to disable CPU mining: %w", err)
+		}
+	}
+
+	// Ensure the wallet processed all blocks before resetting it, so that
+	// no stale updates are applied afterwards.
+	if err := h.waitWalletSynced(ctx); err != nil {
+		return err
+	}
+	if err := h.stopNode(); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(h.node.config.dataDir); err != nil {
+		return err
+	}
+	h.wallet.reset()
+	if err := h.startNode(ctx); err != nil {
+		return err
+	}
+
+	if h.opts.generateThreads > 0 {
+		err := h.Node.SetGenerate(ctx, true, h.opts.generateThreads)
+		if err != nil {
+			return fmt.Errorf("unable to enable CPU mining: %w", err)
+		}
+	}
+	return nil
+}
+
+// retryBackoff calls fn until it succeeds or fails with an error it does not
+// flag as retriable, which is then returned, backing off exponentially between
+// attempts up to a max of one second. When the context is done first, the
+// returned error wraps the error of the context and includes the last error
+// of fn.
+func retryBackoff(ctx context.Context, fn func() (bool, error)) error {
+	const maxBackoff = time.Second
+	backoff := 50 * time.Millisecond
+	for {
+		retry, err := fn()
+		if err == nil || !retry {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// defaultConnectTimeout is the max time connectRPCClient retries connecting
+// to the RPC server of dcrd when the passed context has no deadline.
+const defaultConnectTimeout = 30 * time.Second
+
 // connectRPCClient attempts to establish an RPC connection to the created dcrd
-// process belonging to this Harness instance. If the initial connection
-// attempt fails, this function will retry h.maxConnRetries times, backing off
-// the time between subsequent attempts. If after h.maxConnRetries attempts,
-// we're not able to establish a connection, this function returns with an
-// error.
-func (h *Harness) connectRPCClient() error {
-	var client *rpcclient.Client
-	var err error
+// process belonging to this Harness instance. Connection errors, such as the
+// connection being refused while dcrd is still starting its RPC server, are
+// retried with exponential backoff until the context is done, or for up to
+// defaultConnectTimeout when the context has no deadline, so that a node which
+// never serves RPC (for example, due to --norpc) does not block forever.
+// Errors that retrying cannot fix, such as invalid credentials or the process
+// exiting, are returned immediately.
+func (h *Harness) connectRPCClient(ctx context.Context) error {
+	connCtx := ctx
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		connCtx, cancel = context.WithTimeout(ctx, defaultConnectTimeout)
+		defer cancel()
+	}
 
-	rpcConf := h.node.config.rpcConnConfig()
-	for i := 0; i < h.maxConnRetries; i++ {
-		if client, err = rpcclient.New(&rpcConf, h.handlers); err != nil {
-			time.Sleep(time.Duration(i) * 50 * time.Millisecond)
-			continue
+	var client *rpcclient.Client
+	rpcConf := h.wsRPCConfig()
+	attempt := 0
+	err := retryBackoff(connCtx, func() (bool, error) {
+		attempt++
+		// There is no point in retrying if the process is gone.
+		if err := h.node.earlyExitErr(); err != nil {
+			return false, err
+		}
+		var err error
+		client, err = rpcclient.New(&rpcConf, h.handlers)
+		if err == nil {
+			return false, nil
+		}
+		if errors.Is(err, rpcclient.ErrInvalidAuth) ||
+			errors.Is(err, rpcclient.ErrInvalidEndpoint) ||
+			isCertVerifyError(err) {
+			return false, err
 		}
-		break
+		tracef(h.t, "RPC connection attempt %d failed: %v", attempt, err)
+		return true, err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to connect RPC client: %w", err)
 	}
 
-	if client == nil {
-		return fmt.Errorf("connection timeout")
+	if err := waitRPCReady(ctx, client); err != nil {
+		client.Shutdown()
+		client.WaitForShutdown()
+		return err
 	}
 
+	h.node.setReady()
 	h.Node = client
 	h.wallet.SetRPCClient(client)
 	return nil
 }
 
+// isCertVerifyError returns whether the passed error is due to the failure of
+// verifying the TLS cert of the server, such as when the client does not trust
+// its CA. Such errors are not solved by retrying the connection.
+func isCertVerifyError(err error) bool {
+	var unknownAuthErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	return errors.As(err, &unknownAuthErr) ||
+		errors.As(err, &invalidErr) ||
+		errors.As(err, &hostnameErr)
+}
+
+// rpcReadyTimeout is the max time to wait for the RPC server of dcrd to
+// successfully serve calls after the RPC client connects to it.
+const rpcReadyTimeout = 30 * time.Second
+
+// waitRPCReady probes the RPC server the passed client is connected to with
+// getinfo calls until one succeeds, which ensures the server is able to serve
+// calls and not only to accept connections. An error distinct from the
+// connection errors is returned if no call succeeds within rpcReadyTimeout or
+// before the context is done.
+func waitRPCReady(ctx context.Context, client *rpcclient.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, rpcReadyTimeout)
+	defer cancel()
+
+	var lastErr error
+	return pollUntil(ctx, func() (bool, error) {
+		_, lastErr = client.GetInfo(ctx)
+		if errors.Is(lastErr, rpcclient.ErrClientDisconnect) ||
+			errors.Is(lastErr, rpcclient.ErrClientShutdown) {
+			return false, fmt.Errorf("RPC server disconnected before "+
+				"becoming ready: %w", lastErr)
+		}
+		return lastErr == nil, nil
+	}, func(err error) error {
+		return fmt.Errorf("RPC server did not become ready: %w "+
+			"(last error: %v)", err, lastErr)
+	})
+}
+
 // NewAddress returns a fresh address spendable by the Harness' internal
 // wallet.
 //
@@ -363,6 +952,17 @@ func (h *Harness) NewAddress(ctx context.Context) (stdaddr.Address, error) {
 	return h.wallet.NewAddress(ctx)
 }
 
+// MiningAddresses returns the addresses the blocks mined by the harness' node
+// pay to. The first one is always the coinbase address of the internal wallet,
+// followed by any addresses passed with WithMiningAddresses.
+//
+// This function is safe for concurrent access.
+func (h *Harness) MiningAddresses() []stdaddr.Address {
+	addrs := make([]stdaddr.Address, 0, len(h.opts.miningAddrs)+1)
+	addrs = append(addrs, h.wallet.coinbaseAddr)
+	return append(addrs, h.opts.miningAddrs...)
+}
+
 // ConfirmedBalance returns the confirmed balance of the Harness' internal
 // wallet.
 //
@@ -371,6 +971,23 @@ func (h *Harness) ConfirmedBalance() dcrutil.Amount {
 	return h.wallet.ConfirmedBalance()
 }
 
+// BalanceEquals returns an error if the confirmed balance of the Harness'
+// internal wallet differs from want by more than tolerance.
+//
+// This function is safe for concurrent access.
+func (h *Harness) BalanceEquals(want, tolerance dcrutil.Amount) error {
+	return h.wallet.BalanceEquals(want, tolerance)
+}
+
+// AssertBalance fails the test if the confirmed balance of the Harness'
+// internal wallet differs from want by more than tolerance.
+//
+// This function is safe for concurrent access.
+func (h *Harness) AssertBalance(t testing.TB, want, tolerance dcrutil.Amount) {
+	t.Helper()
+	h.wallet.AssertBalance(t, want, tolerance)
+}
+
 // SendOutputs creates, signs, and finally broadcasts a transaction spending
 // the harness' available mature coinbase outputs creating new outputs
 // according to targetOutputs.
@@ -380,6 +997,133 @@ func (h *Harness) SendOutputs(ctx context.Context, targetOutputs []*wire.TxOut,
 	return h.wallet.SendOutputs(ctx, targetOutputs, feeRate)
 }
 
+// ticketFeeRate is the fee rate, in atoms-per-byte, of the tickets purchased
+// by PurchaseTickets.
+const ticketFeeRate = 10
+
+// PurchaseTickets purchases count tickets at the next stake difficulty using
+// mature outputs of the harness' internal wallet, and returns the hashes of
+// the ticket transactions. Each ticket is funded by a single output, so the
+// wallet must have at least count outputs worth more than the ticket price.
+//
+// The tickets are broadcast one at a time, so when creating or sending one
+// of them fails, the hashes of the tickets already broadcast are returned
+// along with the error. Those tickets remain in the mempool and their inputs
+// stay spent.
+//
+// The voting rights of the tickets are assigned to addresses of the internal
+// wallet, which does not vote. Tests that need the tickets to vote should use
+// VotingWallet instead.
+//
+// This function is safe for concurrent access.
+func (h *Harness) PurchaseTickets(ctx context.Context, count int) ([]*chainhash.Hash, error) {
+	sdiff, err := h.Node.GetStakeDifficulty(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ticketPrice, err := dcrutil.NewAmount(sdiff.NextStakeDifficulty)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]*chainhash.Hash, 0, count)
+	for i := 0; i < count; i++ {
+		tx, err := h.wallet.createTicket(ctx, ticketPrice, ticketFeeRate)
+		if err != nil {
+			return hashes, fmt.Errorf("unable to create ticket %d: %w",
+				i, err)
+		}
+		hash, err := h.Node.SendRawTransaction(ctx, tx, true)
+		if err != nil {
+			h.wallet.UnlockOutputs(tx.TxIn)
+			return hashes, fmt.Errorf("unable to send ticket %d: %w", i,
+				err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// FundAddress sends the specified amount to the passed address, which is
+// usually controlled by an external wallet, spending the harness' available
+// mature coinbase outputs. It returns the hash of the funding transaction,
+// which is broadcast but not mined.
+//
+// This requires the internal wallet to have enough mature funds (see the
+// numMatureOutputs argument of SetUp) to pay for the amount plus fees.
+//
+// This function is safe for concurrent access.
+func (h *Harness) FundAddress(ctx context.Context, addr stdaddr.Address, amount dcrutil.Amount) (*chainhash.Hash, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount to fund must be positive (got %v)",
+			amount)
+	}
+	pkScriptVer, pkScript := addr.PaymentScript()
+	output := newTxOut(int64(amount), pkScriptVer, pkScript)
+	return h.wallet.SendOutputs(ctx, []*wire.TxOut{output}, fundAddressFeeRate)
+}
+
+// Faucet sends the specified amount to the passed address, spending the
+// harness' available mature coinbase outputs, and mines a block to confirm
+// the transaction. It returns the hash of the funding transaction, which pays
+// the amount in its first output.
+//
+// Unlike FundAddress, this does not require the harness to be set up with
+// mature outputs: when the internal wallet does not have enough mature funds,
+// blocks are mined until the coinbase outputs paying to it mature. Note that
+// mining is subject to the same restrictions as GenerateBlocks, so it fails
+// when reaching the stake validation height without enough live tickets.
+//
+// NOTE: This method should not be called concurrently with other methods of
+// the harness that mine blocks.
+func (h *Harness) Faucet(ctx context.Context, addr stdaddr.Address, amount dcrutil.Amount) (*chainhash.Hash, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount to fund must be positive (got %v)",
+			amount)
+	}
+
+	for {
+		if err := h.waitWalletSynced(ctx); err != nil {
+			return nil, err
+		}
+		if h.wallet.ConfirmedBalance() >= amount+faucetFeeMargin {
+			break
+		}
+
+		// Mine enough blocks for a new coinbase output to mature when
+		// the wallet has none, otherwise each new block matures one.
+		numBlocks := uint32(1)
+		if h.wallet.numMatureOutputs() == 0 {
+			numBlocks = uint32(h.ActiveNet.CoinbaseMaturity) + 1
+		}
+		if _, err := h.GenerateBlocks(ctx, numBlocks); err != nil {
+			return nil, fmt.Errorf("unable to mine blocks to fund the "+
+				"faucet: %w", err)
+		}
+	}
+
+	txHash, err := h.FundAddress(ctx, addr, amount)
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := h.GenerateBlocks(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	block, err := h.GetBlock(ctx, hashes[0])
+	if err != nil {
+		return nil, err
+	}
+	if !blockHasTx(block, txHash) {
+		return nil, fmt.Errorf("faucet transaction %v was not mined in "+
+			"block %v", txHash, hashes[0])
+	}
+	if err := h.waitWalletSynced(ctx); err != nil {
+		return nil, err
+	}
+	return txHash, nil
+}
+
 // CreateTransaction returns a fully signed transaction paying to the specified
 // outputs while observing the desired fee rate. The passed fee rate should be
 // expressed in atoms-per-byte. Any unspent outputs selected as inputs for
@@ -394,6 +1138,100 @@ func (h *Harness) CreateTransaction(ctx context.Context, targetOutputs []*wire.T
 	return h.wallet.CreateTransaction(ctx, targetOutputs, feeRate)
 }
 
+// EstimateFeeRate returns the fee rate, in atoms-per-byte, estimated by the
+// harness' node via estimatesmartfee for a transaction to be mined within the
+// passed number of blocks.
+//
+// The node is unable to estimate fees until it has observed enough mined
+// transactions, which is usually the case on simnet and regnet, so the
+// fallback fee rate set with WithFallbackFeeRate (10 atoms-per-byte by
+// default) is returned in that case.
+func (h *Harness) EstimateFeeRate(ctx context.Context, confirmations int64) (dcrutil.Amount, error) {
+	fallback := h.opts.fallbackFeeRate
+	if fallback == 0 {
+		fallback = defaultFallbackFeeRate
+	}
+
+	res, err := h.Node.EstimateSmartFee(ctx, confirmations,
+		dcrdtypes.EstimateSmartFeeConservative)
+	var rpcErr *dcrjson.RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == dcrjson.ErrRPCInternal.Code {
+		return fallback, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	perKB, err := dcrutil.NewAmount(res.FeeRate)
+	if err != nil {
+		return 0, err
+	}
+	if perKB <= 0 {
+		return fallback, nil
+	}
+	return (perKB + 999) / 1000, nil
+}
+
+// estimateFeeConfirmations is the number of blocks within which the
+// transactions created with an estimated fee are expected to be mined.
+const estimateFeeConfirmations = 2
+
+// FundedTx is a transaction created by the harness' internal wallet along
+// with the fee it pays.
+type FundedTx struct {
+	Tx *wire.MsgTx
+
+	// FeeRate is the fee rate, in atoms-per-byte, used to fund the
+	// transaction.
+	FeeRate dcrutil.Amount
+
+	// Fee is the total fee paid by the transaction.
+	Fee dcrutil.Amount
+}
+
+// CreateTransactionEstimatedFee is identical to CreateTransaction, except the
+// fee rate is determined by EstimateFeeRate instead of being passed by the
+// caller. The returned result includes the fee rate and the fee of the
+// transaction.
+//
+// This function is safe for concurrent access.
+func (h *Harness) CreateTransactionEstimatedFee(ctx context.Context, targetOutputs []*wire.TxOut) (*FundedTx, error) {
+	feeRate, err := h.EstimateFeeRate(ctx, estimateFeeConfirmations)
+	if err != nil {
+		return nil, fmt.Errorf("unable to estimate fee rate: %w", err)
+	}
+	tx, err := h.wallet.CreateTransaction(ctx, targetOutputs, feeRate)
+	if err != nil {
+		return nil, err
+	}
+
+	var fee int64
+	for _, in := range tx.TxIn {
+		fee += in.ValueIn
+	}
+	for _, out := range tx.TxOut {
+		fee -= out.Value
+	}
+	return &FundedTx{Tx: tx, FeeRate: feeRate, Fee: dcrutil.Amount(fee)}, nil
+}
+
+// SendOutputsEstimatedFee is identical to SendOutputs, except the fee rate is
+// determined by EstimateFeeRate instead of being passed by the caller. The
+// returned result includes the broadcast transaction along with its fee rate
+// and fee.
+//
+// This function is safe for concurrent access.
+func (h *Harness) SendOutputsEstimatedFee(ctx context.Context, targetOutputs []*wire.TxOut) (*FundedTx, error) {
+	funded, err := h.CreateTransactionEstimatedFee(ctx, targetOutputs)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := h.Node.SendRawTransaction(ctx, funded.Tx, true); err != nil {
+		h.wallet.UnlockOutputs(funded.Tx.TxIn)
+		return nil, err
+	}
+	return funded, nil
+}
+
 // UnlockOutputs unlocks any outputs which were previously marked as
 // unspendable due to being selected to fund a transaction via the
 // CreateTransaction method.
@@ -406,8 +1244,76 @@ func (h *Harness) UnlockOutputs(inputs []*wire.TxIn) {
 // RPCConfig returns the harnesses current rpc configuration. This allows other
 // potential RPC clients created within tests to connect to a given test
 // harness instance.
+//
+// When RPC tracing is enabled, the returned config points to the tracing
+// proxy, such that calls made by clients created from it are also traced. The
+// config uses the endpoint set with WithRPCEndpoint.
 func (h *Harness) RPCConfig() rpcclient.ConnConfig {
-	return h.node.config.rpcConnConfig()
+	cfg := h.node.config.rpcConnConfig()
+	if h.tracer != nil {
+		cfg.Host = h.tracer.addr()
+	}
+	return cfg
+}
+
+// wsRPCConfig returns the config returned by RPCConfig using websockets
+// regardless of the endpoint set with WithRPCEndpoint, which is required by
+// clients that rely on notifications.
+func (h *Harness) wsRPCConfig() rpcclient.ConnConfig {
+	cfg := h.RPCConfig()
+	cfg.Endpoint = wsEndpoint
+	cfg.HTTPPostMode = false
+	return cfg
+}
+
+// LimitedRPCClient returns a new RPC client connected to the harness node and
+// authenticated as the limited RPC user set with WithRPCLimitedUser, which is
+// only allowed to issue the calls dcrd considers safe for untrusted clients.
+// The caller is responsible for shutting down the client.
+func (h *Harness) LimitedRPCClient() (*rpcclient.Client, error) {
+	cfg := h.RPCConfig()
+	if h.node.config.rpcLimitUser == "" {
+		return nil, errors.New("limited RPC user is not enabled")
+	}
+	cfg.User = h.node.config.rpcLimitUser
+	cfg.Pass = h.node.config.rpcLimitPass
+	return rpcclient.New(&cfg, nil)
+}
+
+// OpenRPCClients opens n new RPC clients connected to the harness node using
+// the config returned by RPCConfig, which allows stressing the RPC server
+// and asserting its behavior at and beyond the limits set with
+// WithRPCMaxWebsockets and WithRPCMaxConcurrentRequests.
+//
+// The clients are opened one after the other, and each one must successfully
+// serve a getblockcount call before the next one is opened, so that a client
+// rejected by the server is detected. On error, the clients opened so far are
+// shut down, and the error states how many clients were opened. Otherwise, the
+// caller is responsible for shutting down the clients.
+func (h *Harness) OpenRPCClients(ctx context.Context, n int) ([]*rpcclient.Client, error) {
+	rpcConf := h.RPCConfig()
+	clients := make([]*rpcclient.Client, 0, n)
+	shutdown := func() {
+		for _, client := range clients {
+			client.Shutdown()
+			client.WaitForShutdown()
+		}
+	}
+	for i := 0; i < n; i++ {
+		client, err := rpcclient.New(&rpcConf, nil)
+		if err != nil {
+			shutdown()
+			return nil, fmt.Errorf("unable to connect RPC client %d "+
+				"after opening %d: %w", i, len(clients), err)
+		}
+		clients = append(clients, client)
+		if _, err := client.GetBlockCount(ctx); err != nil {
+			shutdown()
+			return nil, fmt.Errorf("RPC client %d failed after opening "+
+				"%d: %w", i, i, err)
+		}
+	}
+	return clients, nil
 }
 
 // P2PAddress returns the harness node's configured listening address for P2P
@@ -417,7 +1323,50 @@ func (h *Harness) RPCConfig() rpcclient.ConnConfig {
 // ConnectNode() function, which handles cases like already connected peers and
 // ensures the connection actually takes place.
 func (h *Harness) P2PAddress() string {
-	return h.node.config.listen
+	return dialAddr(h.node.config.listen)
+}
+
+// RPCAddress returns the harness node's configured listening address for RPC
+// connections.
+//
+// Note that when RPC tracing is enabled, clients that should be traced must
+// connect to the address in the config returned by RPCConfig instead.
+func (h *Harness) RPCAddress() string {
+	return dialAddr(h.node.config.rpcListen)
+}
+
+// Args returns the command line used to launch the dcrd process of the
+// harness: the path of the dcrd executable followed by its arguments, as
+// resolved from the options and extra args of the harness. This is useful to
+// diagnose why dcrd failed to start. The entries set with WithConfigFile are
+// not included, since they are passed to dcrd in the file given by
+// --configfile.
+//
+// The values of the credential flags (--rpcpass and --rpclimitpass) are
+// replaced by a placeholder. The command line is also logged, in the same
+// form, when the node starts if debug logging is enabled.
+func (h *Harness) Args() []string {
+	return redactArgs(h.node.cmd.Args)
+}
+
+// TLSCertPath returns the path to the TLS certificate file used by the RPC
+// server of the harness node.
+//
+// The certificate is generated when the harness is created, so the file
+// exists for the entire life of the harness, up until it is torn down. When
+// provided with WithTLSFiles, this is the provided path instead.
+func (h *Harness) TLSCertPath() string {
+	return h.node.config.certFile
+}
+
+// TLSKeyPath returns the path to the TLS key file used by the RPC server of
+// the harness node.
+//
+// The key is generated when the harness is created, so the file exists for
+// the entire life of the harness, up until it is torn down. When provided with
+// WithTLSFiles, this is the provided path instead.
+func (h *Harness) TLSKeyPath() string {
+	return h.node.config.keyFile
 }
 
 // generateListeningAddresses returns two strings representing listening
@@ -6,16 +6,26 @@
 package dcrdtest
 
 import (
+	"bytes"
 	"context"
+	"crypto/elliptic"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/decred/dcrd/blockchain/standalone/v2"
 	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/chaincfg/v3"
 	"github.com/decred/dcrd/dcrutil/v4"
 	dcrdtypes "github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+	"github.com/decred/dcrd/rpcclient/v8"
+	"github.com/decred/dcrd/txscript/v4/stdaddr"
 	"github.com/decred/dcrd/wire"
 )
 
@@ -100,6 +110,334 @@ func testSendOutputs(ctx context.Context, r *Harness, t *testing.T) {
 	}
 }
 
+func testBlockchainInfo(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testBlockchainInfo start")
+	defer tracef(t, "testBlockchainInfo end")
+
+	bestHash, bestHeight, err := r.BestBlock(ctx)
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	info, err := r.BlockchainInfo(ctx)
+	if err != nil {
+		t.Fatalf("unable to get blockchain info: %v", err)
+	}
+	if info.Chain != r.ActiveNet.Name {
+		t.Fatalf("unexpected chain: got %s, want %s", info.Chain,
+			r.ActiveNet.Name)
+	}
+	if info.Blocks != bestHeight || info.BestBlockHash != *bestHash {
+		t.Fatalf("unexpected best block: got %v (%d), want %v (%d)",
+			info.BestBlockHash, info.Blocks, bestHash, bestHeight)
+	}
+	if info.ChainWork.Sign() <= 0 {
+		t.Fatalf("unexpected chain work %v", info.ChainWork)
+	}
+}
+
+func testAssertChainMatches(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testAssertChainMatches start")
+	defer tracef(t, "testAssertChainMatches end")
+
+	goldenPath := filepath.Join(t.TempDir(), "testdata", "chain.golden")
+	if err := r.AssertChainMatches(ctx, goldenPath); err == nil {
+		t.Fatal("expected an error for a missing golden file")
+	}
+
+	UpdateGoldenFiles = true
+	err := r.AssertChainMatches(ctx, goldenPath)
+	UpdateGoldenFiles = false
+	if err != nil {
+		t.Fatalf("unable to update golden file: %v", err)
+	}
+	if err := r.AssertChainMatches(ctx, goldenPath); err != nil {
+		t.Fatalf("chain does not match golden file: %v", err)
+	}
+
+	if _, err := r.Node.Generate(ctx, 1); err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+	if err := r.AssertChainMatches(ctx, goldenPath); err == nil {
+		t.Fatal("expected an error after extending the chain")
+	}
+}
+
+func testGenerateBlockAtTime(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testGenerateBlockAtTime start")
+	defer tracef(t, "testGenerateBlockAtTime end")
+
+	mtp, err := r.MedianTimePast(ctx)
+	if err != nil {
+		t.Fatalf("unable to get median time past: %v", err)
+	}
+	if _, err := r.GenerateBlockAtTime(ctx, mtp); err == nil {
+		t.Fatal("expected an error for a timestamp equal to the MTP")
+	}
+
+	wantTime := mtp.Add(time.Second)
+	hash, err := r.GenerateBlockAtTime(ctx, wantTime)
+	if err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+	header, err := r.Node.GetBlockHeader(ctx, hash)
+	if err != nil {
+		t.Fatalf("unable to get block header: %v", err)
+	}
+	if !header.Timestamp.Equal(wantTime) {
+		t.Fatalf("unexpected block timestamp: got %v, want %v",
+			header.Timestamp, wantTime)
+	}
+	bestHash, err := r.Node.GetBestBlockHash(ctx)
+	if err != nil {
+		t.Fatalf("unable to get best block hash: %v", err)
+	}
+	if *bestHash != *hash {
+		t.Fatalf("generated block %v is not the tip %v", hash, bestHash)
+	}
+}
+
+func testConcurrentGenerateBlocks(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testConcurrentGenerateBlocks start")
+	defer tracef(t, "testConcurrentGenerateBlocks end")
+
+	// Use a fresh harness with the CPU miner enabled, so that manual mining
+	// overlaps with automatic mining.
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil,
+		WithGenerateThreads(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	const numCalls, numBlocks = 4, 3
+	var wg sync.WaitGroup
+	results := make([][]*chainhash.Hash, numCalls)
+	errs := make([]error, numCalls)
+	for i := 0; i < numCalls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = harness.GenerateBlocks(ctx, numBlocks)
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[chainhash.Hash]struct{})
+	for i := 0; i < numCalls; i++ {
+		if errs[i] != nil {
+			t.Fatalf("call %d: unable to generate blocks: %v", i, errs[i])
+		}
+		if len(results[i]) != numBlocks {
+			t.Fatalf("call %d: got %d hashes, want %d", i,
+				len(results[i]), numBlocks)
+		}
+		for _, hash := range results[i] {
+			if _, ok := seen[*hash]; ok {
+				t.Fatalf("call %d: block %v returned twice", i, hash)
+			}
+			seen[*hash] = struct{}{}
+		}
+	}
+}
+
+func testGenerateBlocksPastSVH(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testGenerateBlocksPastSVH start")
+	defer tracef(t, "testGenerateBlocksPastSVH end")
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	hashes, err := harness.GenerateBlocks(ctx, 2)
+	if err != nil {
+		t.Fatalf("unable to generate blocks: %v", err)
+	}
+	if len(hashes) != 2 {
+		t.Fatalf("unexpected number of hashes: got %d, want 2", len(hashes))
+	}
+
+	// There are no tickets, so generating blocks up to the stake validation
+	// height must fail instead of waiting for votes.
+	numBlocks := uint32(harness.StakeValidationHeight())
+	_, err = harness.GenerateBlocks(ctx, numBlocks)
+	if err == nil || !strings.Contains(err.Error(), "live tickets") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func testAssertBlockContainsTxs(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testAssertBlockContainsTxs start")
+	defer tracef(t, "testAssertBlockContainsTxs end")
+
+	addr, err := r.NewAddress(ctx)
+	if err != nil {
+		t.Fatalf("unable to get new address: %v", err)
+	}
+	addrScriptVer, addrScript := addr.PaymentScript()
+	output := newTxOut(1e8, addrScriptVer, addrScript)
+	txid, err := r.SendOutputs(ctx, []*wire.TxOut{output}, 10000)
+	if err != nil {
+		t.Fatalf("coinbase spend failed: %v", err)
+	}
+	if err := r.Node.RegenTemplate(ctx); err != nil {
+		t.Fatalf("unable to regenerate block template: %v", err)
+	}
+	time.Sleep(time.Millisecond * 500)
+	hashes, err := r.Node.Generate(ctx, 1)
+	if err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+
+	if err := r.AssertBlockContainsTxs(ctx, hashes[0], []*chainhash.Hash{txid}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	coinbase, err := r.CoinbaseTx(ctx, hashes[0])
+	if err != nil {
+		t.Fatalf("unable to get coinbase: %v", err)
+	}
+	if !standalone.IsCoinBaseTx(coinbase, noTreasury) {
+		t.Fatalf("transaction %v is not a coinbase", coinbase.TxHash())
+	}
+	if _, err := r.CoinbaseTx(ctx, &chainhash.Hash{0x01}); err == nil {
+		t.Fatal("expected an error for an unknown block")
+	}
+	if err := r.AssertBlockContainsTxs(ctx, hashes[0], nil); err == nil {
+		t.Fatal("expected an error for an unexpected transaction")
+	}
+	missing := &chainhash.Hash{0x01}
+	err = r.AssertBlockContainsTxs(ctx, hashes[0], []*chainhash.Hash{txid, missing})
+	if err == nil {
+		t.Fatal("expected an error for a missing transaction")
+	}
+}
+
+func testBlockSubsidy(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testBlockSubsidy start")
+	defer tracef(t, "testBlockSubsidy end")
+
+	// Use a fresh harness, so that the mined blocks do not include fees.
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	// Block one has special subsidy rules, so check the second block.
+	hashes, err := harness.Node.Generate(ctx, 2)
+	if err != nil {
+		t.Fatalf("unable to generate blocks: %v", err)
+	}
+	work, stake, _, err := harness.BlockSubsidy(ctx, 2)
+	if err != nil {
+		t.Fatalf("unable to calculate block subsidy: %v", err)
+	}
+	if stake != 0 {
+		t.Fatalf("unexpected stake subsidy before SVH: %v", stake)
+	}
+
+	coinbase, err := harness.CoinbaseTx(ctx, hashes[1])
+	if err != nil {
+		t.Fatalf("unable to get coinbase: %v", err)
+	}
+	_, walletScript := harness.MiningAddresses()[0].PaymentScript()
+	var paid dcrutil.Amount
+	for _, out := range coinbase.TxOut {
+		if bytes.Equal(out.PkScript, walletScript) {
+			paid += dcrutil.Amount(out.Value)
+		}
+	}
+	if paid != work {
+		t.Fatalf("unexpected work subsidy: coinbase pays %v, want %v",
+			paid, work)
+	}
+}
+
+func testWaitForAgendaStatus(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testWaitForAgendaStatus start")
+	defer tracef(t, "testWaitForAgendaStatus end")
+
+	info, err := r.BlockchainInfo(ctx)
+	if err != nil {
+		t.Fatalf("unable to get blockchain info: %v", err)
+	}
+	for id, deployment := range info.Deployments {
+		if err := r.WaitForAgendaStatus(ctx, id, deployment.Status); err != nil {
+			t.Fatalf("unexpected error waiting for current status: %v",
+				err)
+		}
+
+		shortCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		err := r.WaitForAgendaStatus(shortCtx, id, "invalidstatus")
+		cancel()
+		if err == nil || !strings.Contains(err.Error(), deployment.Status) {
+			t.Fatalf("expected an error including the current "+
+				"status %q, got %v", deployment.Status, err)
+		}
+		break
+	}
+}
+
+func testTxOut(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testTxOut start")
+	defer tracef(t, "testTxOut end")
+
+	addr, err := r.NewAddress(ctx)
+	if err != nil {
+		t.Fatalf("unable to get new address: %v", err)
+	}
+	amt := dcrutil.Amount(5 * dcrutil.AtomsPerCoin)
+	addrScriptVer, addrScript := addr.PaymentScript()
+	output := newTxOut(int64(amt), addrScriptVer, addrScript)
+	txid, err := r.SendOutputs(ctx, []*wire.TxOut{output}, 10000)
+	if err != nil {
+		t.Fatalf("coinbase spend failed: %v", err)
+	}
+
+	// The output is only found when including the mempool.
+	txOut, err := r.TxOut(ctx, txid, 0, false)
+	if err != nil {
+		t.Fatalf("unable to query txout: %v", err)
+	}
+	if txOut != nil {
+		t.Fatalf("unexpected txout of mempool tx: %v", txOut)
+	}
+	txOut, err = r.TxOut(ctx, txid, 0, true)
+	if err != nil {
+		t.Fatalf("unable to query txout: %v", err)
+	}
+	if txOut == nil || txOut.Value != amt || txOut.Confirmations != 0 {
+		t.Fatalf("unexpected mempool txout: %v", txOut)
+	}
+
+	// Mine the transaction and ensure the output is confirmed.
+	if err := r.Node.RegenTemplate(ctx); err != nil {
+		t.Fatalf("unable to regenerate block template: %v", err)
+	}
+	time.Sleep(time.Millisecond * 500)
+	if _, err := r.Node.Generate(ctx, 1); err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+	txOut, err = r.TxOut(ctx, txid, 0, false)
+	if err != nil {
+		t.Fatalf("unable to query txout: %v", err)
+	}
+	if txOut == nil || txOut.Confirmations != 1 || txOut.Coinbase {
+		t.Fatalf("unexpected confirmed txout: %v", txOut)
+	}
+}
+
 func assertConnectedTo(ctx context.Context, t *testing.T, nodeA *Harness, nodeB *Harness) {
 	tracef(t, "assertConnectedTo start")
 	defer tracef(t, "assertConnectedTo end")
@@ -315,6 +653,9 @@ func testTearDownAll(t *testing.T) {
 	if len(ActiveHarnesses()) != 0 {
 		t.Fatalf("test harnesses still active after TearDownAll")
 	}
+	if n := ActiveNodeCount(); n != 0 {
+		t.Fatalf("%d dcrd processes still running after TearDownAll", n)
+	}
 
 	for _, harness := range initialActiveHarnesses {
 		// Ensure all test directories have been deleted.
@@ -477,12 +818,10 @@ func testJoinMempools(ctx context.Context, r *Harness, t *testing.T) {
 	}
 }
 
-func testJoinBlocks(ctx context.Context, r *Harness, t *testing.T) {
-	tracef(t, "testJoinBlocks start")
-	defer tracef(t, "testJoinBlocks end")
+func testSetRelay(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testSetRelay start")
+	defer tracef(t, "testSetRelay end")
 
-	// Create a second harness with only the genesis block so it is behind
-	// the main harness.
 	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
 	if err != nil {
 		t.Fatal(err)
@@ -492,100 +831,1415 @@ func testJoinBlocks(ctx context.Context, r *Harness, t *testing.T) {
 	}
 	defer harness.TearDown()
 
-	nodeSlice := []*Harness{r, harness}
-	blocksSynced := make(chan error)
-	go func() {
-		if err := JoinNodes(ctx, nodeSlice, Blocks); err != nil {
-			blocksSynced <- fmt.Errorf("unable to join node on blocks: %w", err)
-			return
-		}
-		blocksSynced <- nil
-	}()
-
-	// This select case should fall through to the default as the goroutine
-	// should be blocked on the JoinNodes calls.
-	select {
-	case err := <-blocksSynced:
-		if err != nil {
-			t.Fatal(err)
-		}
-		t.Fatalf("blocks detected as synced yet local harness is behind")
-	default:
-	}
-
-	// Connect the local harness to the main harness which will sync the
-	// chains.
+	nodes := []*Harness{r, harness}
 	if err := ConnectNode(ctx, harness, r); err != nil {
 		t.Fatalf("unable to connect harnesses: %v", err)
 	}
+	if err := JoinNodes(ctx, nodes, Blocks); err != nil {
+		t.Fatalf("unable to join node on blocks: %v", err)
+	}
 
-	// Select once again with a special timeout case after 1 minute. The
-	// goroutine above should now be blocked on sending into the unbuffered
-	// channel. The send should immediately succeed. In order to avoid the
-	// test hanging indefinitely, a 1 minute timeout is in place.
-	select {
-	case err := <-blocksSynced:
-		if err != nil {
-			t.Fatal(err)
-		}
-	case <-time.After(time.Minute):
-		t.Fatalf("blocks never detected as synced")
+	// Blocks mined while relay is disabled stay local.
+	if err := harness.SetRelay(ctx, false); err != nil {
+		t.Fatalf("unable to disable relay: %v", err)
+	}
+	if _, err := harness.Node.Generate(ctx, 1); err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+	shortCtx, cancel := context.WithTimeout(ctx, time.Second)
+	err = WaitForConvergence(shortCtx, nodes)
+	cancel()
+	if err == nil {
+		t.Fatal("nodes converged while relay was disabled")
+	}
+
+	// The blocks are relayed once relay is enabled again.
+	if err := harness.SetRelay(ctx, true); err != nil {
+		t.Fatalf("unable to enable relay: %v", err)
+	}
+	convergeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := WaitForConvergence(convergeCtx, nodes); err != nil {
+		t.Fatalf("nodes did not converge after enabling relay: %v", err)
 	}
 }
 
-func testMemWalletReorg(ctx context.Context, r *Harness, t *testing.T) {
-	tracef(t, "testMemWalletReorg start")
-	defer tracef(t, "testMemWalletReorg end")
+func testSendRawP2PMessage(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testSendRawP2PMessage start")
+	defer tracef(t, "testSendRawP2PMessage end")
 
-	// Create a fresh harness, we'll be using the main harness to force a
-	// re-org on this local harness.
+	// Use a fresh harness, since the node bans the host of the peer,
+	// which would prevent other local connections to the main harness.
 	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := harness.SetUp(ctx, true, 5); err != nil {
+	if err := harness.SetUp(ctx, false, 0); err != nil {
 		t.Fatalf("unable to complete harness setup: %v", err)
 	}
 	defer harness.TearDown()
 
-	// Ensure the internal wallet has the expected balance.
-	expectedBalance := dcrutil.Amount(5 * 300 * dcrutil.AtomsPerCoin)
-	walletBalance := harness.ConfirmedBalance()
-	if expectedBalance != walletBalance {
-		t.Fatalf("wallet balance incorrect: expected %v, got %v",
-			expectedBalance, walletBalance)
+	peerAddr, err := harness.DialP2P(ctx)
+	if err != nil {
+		t.Fatalf("unable to dial node: %v", err)
 	}
 
-	// Now connect this local harness to the main harness then wait for
-	// their chains to synchronize.
-	if err := ConnectNode(ctx, harness, r); err != nil {
-		t.Fatalf("unable to connect harnesses: %v", err)
+	banCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	banned := make(chan error, 1)
+	go func() {
+		banned <- harness.WaitForPeerBan(banCtx, peerAddr, "mempool")
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// Each mempool message increases the ban score of the peer by 33, so
+	// the fourth one crosses the ban threshold.
+	for i := 0; i < 4; i++ {
+		if err := harness.SendRawP2PMessage(ctx, wire.NewMsgMemPool()); err != nil {
+			t.Fatalf("unable to send message: %v", err)
+		}
 	}
-	nodeSlice := []*Harness{r, harness}
-	if err := JoinNodes(ctx, nodeSlice, Blocks); err != nil {
-		t.Fatalf("unable to join node on blocks: %v", err)
+	if err := <-banned; err != nil {
+		t.Fatalf("peer was not banned: %v", err)
 	}
+}
 
-	// The original wallet should now have a balance of 0 Coin as its entire
-	// chain should have been decimated in favor of the main harness'
-	// chain.
-	expectedBalance = dcrutil.Amount(0)
-	walletBalance = harness.ConfirmedBalance()
-	if expectedBalance != walletBalance {
-		t.Fatalf("wallet balance incorrect: expected %v, got %v",
-			expectedBalance, walletBalance)
+func testLocalServices(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testLocalServices start")
+	defer tracef(t, "testLocalServices end")
+
+	services, err := r.LocalServices(ctx)
+	if err != nil {
+		t.Fatalf("unable to fetch local services: %v", err)
+	}
+	want := wire.SFNodeNetwork | wire.SFNodeCF
+	if services&want != want {
+		t.Fatalf("node does not advertise the expected services: got "+
+			"%v, want %v", services, want)
 	}
 }
 
-func testMemWalletLockedOutputs(ctx context.Context, r *Harness, t *testing.T) {
-	tracef(t, "testMemWalletLockedOutputs start")
-	defer tracef(t, "testMemWalletLockedOutputs end")
-
-	// Obtain the initial balance of the wallet at this point.
-	startingBalance := r.ConfirmedBalance()
+func testWaitForBlockHeight(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testWaitForBlockHeight start")
+	defer tracef(t, "testWaitForBlockHeight end")
 
-	// First, create a signed transaction spending some outputs.
-	addr, err := r.NewAddress(ctx)
+	// Create a second harness with only the genesis block so it is behind
+	// the main harness.
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	height, err := r.Node.GetBlockCount(ctx)
+	if err != nil {
+		t.Fatalf("unable to get block count: %v", err)
+	}
+
+	// The node does not reach the height while disconnected.
+	waitCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	err = harness.WaitForBlockHeight(waitCtx, height)
+	cancel()
+	if err == nil || !strings.Contains(err.Error(), "current height: 0") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := ConnectNode(ctx, harness, r); err != nil {
+		t.Fatalf("unable to connect harnesses: %v", err)
+	}
+	waitCtx, cancel = context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	if err := harness.WaitForBlockHeight(waitCtx, height); err != nil {
+		t.Fatalf("node did not sync: %v", err)
+	}
+}
+
+func testNotifyBlocks(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testNotifyBlocks start")
+	defer tracef(t, "testNotifyBlocks end")
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	ntfns, err := harness.NotifyBlocks()
+	if err != nil {
+		t.Fatalf("unable to register for notifications: %v", err)
+	}
+	hashes, err := harness.GenerateBlocks(ctx, 2)
+	if err != nil {
+		t.Fatalf("unable to generate blocks: %v", err)
+	}
+	for i, hash := range hashes {
+		select {
+		case ntfn := <-ntfns:
+			if got := ntfn.Header.BlockHash(); got != *hash {
+				t.Fatalf("notification %d: got block %v, want %v",
+					i, got, hash)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("notification %d was not received", i)
+		}
+	}
+
+	// The channel is closed once the harness is torn down.
+	if err := harness.TearDown(); err != nil {
+		t.Fatalf("unable to tear down harness: %v", err)
+	}
+	for range ntfns {
+	}
+}
+
+func testNotifyNewTransactions(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testNotifyNewTransactions start")
+	defer tracef(t, "testNotifyNewTransactions end")
+
+	ntfns, err := r.NotifyNewTransactions(ctx, true)
+	if err != nil {
+		t.Fatalf("unable to register for notifications: %v", err)
+	}
+	if _, err := r.NotifyNewTransactions(ctx, false); err == nil {
+		t.Fatal("expected an error when mixing verbosities")
+	}
+
+	addr, err := r.NewAddress(ctx)
+	if err != nil {
+		t.Fatalf("unable to get new address: %v", err)
+	}
+	addrScriptVer, addrScript := addr.PaymentScript()
+	output := newTxOut(1e8, addrScriptVer, addrScript)
+	txid, err := r.SendOutputs(ctx, []*wire.TxOut{output}, 10000)
+	if err != nil {
+		t.Fatalf("unable to send outputs: %v", err)
+	}
+
+	select {
+	case ntfn := <-ntfns:
+		if ntfn.Hash != *txid {
+			t.Fatalf("got tx %v, want %v", ntfn.Hash, txid)
+		}
+		if ntfn.Details == nil {
+			t.Fatal("verbose notification without tx details")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("notification was not received")
+	}
+
+	// Mine the transaction to leave the mempool empty.
+	if _, err := r.Node.Generate(ctx, 1); err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+}
+
+func testPurchaseTickets(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testPurchaseTickets start")
+	defer tracef(t, "testPurchaseTickets end")
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, true, 4); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	// Tickets may only be purchased once stake is enabled.
+	height, err := harness.Node.GetBlockCount(ctx)
+	if err != nil {
+		t.Fatalf("unable to get block count: %v", err)
+	}
+	if seh := harness.StakeEnabledHeight(); height < seh {
+		_, err := harness.GenerateBlocks(ctx, uint32(seh-height))
+		if err != nil {
+			t.Fatalf("unable to generate blocks: %v", err)
+		}
+	}
+	if err := harness.waitWalletSynced(ctx); err != nil {
+		t.Fatalf("wallet did not sync: %v", err)
+	}
+
+	tickets, err := harness.PurchaseTickets(ctx, 2)
+	if err != nil {
+		t.Fatalf("unable to purchase tickets: %v", err)
+	}
+	mempoolTickets, err := harness.Node.GetRawMempool(ctx,
+		dcrdtypes.GRMTickets)
+	if err != nil {
+		t.Fatalf("unable to get mempool tickets: %v", err)
+	}
+	for _, ticket := range tickets {
+		var found bool
+		for _, hash := range mempoolTickets {
+			found = found || *hash == *ticket
+		}
+		if !found {
+			t.Fatalf("ticket %v not found in the mempool", ticket)
+		}
+	}
+}
+
+func testSetUpStakeChain(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testSetUpStakeChain start")
+	defer tracef(t, "testSetUpStakeChain end")
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	vw, err := harness.SetUpStakeChain(ctx, 5)
+	if err != nil {
+		t.Fatalf("unable to set up stake chain: %v", err)
+	}
+	vw.SetErrorReporting(func(err error) {
+		t.Errorf("voting wallet errored: %v", err)
+	})
+	defer vw.SetErrorReporting(nil)
+
+	_, height, err := harness.Node.GetBestBlock(ctx)
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	if svh := harness.StakeValidationHeight(); height <= svh {
+		t.Fatalf("chain height %d is not past SVH %d", height, svh)
+	}
+	if n := harness.wallet.numMatureOutputs(); n < 5 {
+		t.Fatalf("unexpected number of mature outputs: %d", n)
+	}
+
+	// The chain keeps going with the voting wallet.
+	if _, err := vw.GenerateBlocks(ctx, 2); err != nil {
+		t.Fatalf("unable to generate blocks: %v", err)
+	}
+}
+
+func testSnapshotDataDir(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testSnapshotDataDir start")
+	defer tracef(t, "testSnapshotDataDir end")
+
+	snapshot := t.TempDir()
+	if err := r.SnapshotDataDir(ctx, snapshot); err != nil {
+		t.Fatalf("unable to snapshot data dir: %v", err)
+	}
+	tip, err := r.Node.GetBestBlockHash(ctx)
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+
+	// A harness seeded with the snapshot starts at the same tip.
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil,
+		WithSeedDataDir(snapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+	if err := harness.AssertTip(ctx, tip); err != nil {
+		t.Fatalf("seeded harness did not start at the snapshot tip: %v",
+			err)
+	}
+}
+
+func testRestart(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testRestart start")
+	defer tracef(t, "testRestart end")
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	hashes, err := harness.GenerateBlocks(ctx, 2)
+	if err != nil {
+		t.Fatalf("unable to generate blocks: %v", err)
+	}
+	if err := harness.Restart(ctx); err != nil {
+		t.Fatalf("unable to restart harness: %v", err)
+	}
+	if err := harness.AssertTip(ctx, hashes[1]); err != nil {
+		t.Fatalf("chain not preserved across restart: %v", err)
+	}
+
+	// The restarted node keeps working.
+	if _, err := harness.GenerateBlocks(ctx, 1); err != nil {
+		t.Fatalf("unable to generate blocks after restart: %v", err)
+	}
+}
+
+func testLimitedRPCClient(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testLimitedRPCClient start")
+	defer tracef(t, "testLimitedRPCClient end")
+
+	if _, err := r.LimitedRPCClient(); err == nil {
+		t.Fatal("expected an error without a limited RPC user")
+	}
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil,
+		WithRPCLimitedUser("limited", "limitedpass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	client, err := harness.LimitedRPCClient()
+	if err != nil {
+		t.Fatalf("unable to create limited client: %v", err)
+	}
+	defer client.Shutdown()
+
+	if _, err := client.GetBlockCount(ctx); err != nil {
+		t.Fatalf("unable to issue limited call: %v", err)
+	}
+	if _, err := client.Generate(ctx, 1); err == nil {
+		t.Fatal("privileged call succeeded over limited connection")
+	}
+}
+
+func testTxIndexDisabled(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testTxIndexDisabled start")
+	defer tracef(t, "testTxIndexDisabled end")
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil,
+		WithTxIndex(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	hashes, err := harness.Node.Generate(ctx, 1)
+	if err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+	coinbase, err := harness.CoinbaseTx(ctx, hashes[0])
+	if err != nil {
+		t.Fatalf("unable to fetch coinbase: %v", err)
+	}
+	coinbaseHash := coinbase.TxHash()
+	_, err = harness.Node.GetRawTransaction(ctx, &coinbaseHash)
+	const wantErr = "transaction index must be enabled"
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Fatalf("unexpected error: got %v, want %q", err, wantErr)
+	}
+}
+
+func testExistsAddress(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testExistsAddress start")
+	defer tracef(t, "testExistsAddress end")
+
+	// The coinbase address of the harness was used by the mined blocks,
+	// while a new address was not.
+	exists, err := r.ExistsAddress(ctx, r.wallet.coinbaseAddr)
+	if err != nil {
+		t.Fatalf("unable to query address: %v", err)
+	}
+	if !exists {
+		t.Fatal("coinbase address not found in the index")
+	}
+	addr, err := r.NewAddress(ctx)
+	if err != nil {
+		t.Fatalf("unable to get new address: %v", err)
+	}
+	exists, err = r.ExistsAddress(ctx, addr)
+	if err != nil {
+		t.Fatalf("unable to query address: %v", err)
+	}
+	if exists {
+		t.Fatal("unused address found in the index")
+	}
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil,
+		WithExistsAddrIndex(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+	if _, err := harness.ExistsAddress(ctx, addr); err == nil {
+		t.Fatal("expected an error with the index disabled")
+	}
+}
+
+func testResourceUsage(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testResourceUsage start")
+	defer tracef(t, "testResourceUsage end")
+
+	if r.Pid() == 0 {
+		t.Fatal("running node has no pid")
+	}
+	if runtime.GOOS != "linux" {
+		return
+	}
+	usage, err := r.ResourceUsage()
+	if err != nil {
+		t.Fatalf("unable to read resource usage: %v", err)
+	}
+	if usage.RSS == 0 || usage.CPUTime == 0 {
+		t.Fatalf("unexpected resource usage %+v", usage)
+	}
+}
+
+func testReorgTo(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testReorgTo start")
+	defer tracef(t, "testReorgTo end")
+
+	var nodes []*Harness
+	for i := 0; i < 2; i++ {
+		harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := harness.SetUp(ctx, false, 0); err != nil {
+			t.Fatalf("unable to complete harness setup: %v", err)
+		}
+		defer harness.TearDown()
+		nodes = append(nodes, harness)
+	}
+	a, b := nodes[0], nodes[1]
+	if err := ConnectNode(ctx, a, b); err != nil {
+		t.Fatalf("unable to connect nodes: %v", err)
+	}
+	if err := WaitForConvergence(ctx, nodes); err != nil {
+		t.Fatal(err)
+	}
+	_, startHeight, err := a.BestBlock(ctx)
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+
+	if _, err := ReorgTo(ctx, a, b, 2, 2); err == nil {
+		t.Fatal("expected an error for chains of the same length")
+	}
+
+	// Node a reorganizes to the longer chain of node b.
+	tip, err := ReorgTo(ctx, a, b, 2, 3)
+	if err != nil {
+		t.Fatalf("unable to reorg: %v", err)
+	}
+	_, height, err := a.BestBlock(ctx)
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	if height != startHeight+3 {
+		t.Fatalf("unexpected height of tip %v: got %d, want %d", tip,
+			height, startHeight+3)
+	}
+
+	// The nodes are reconnected, so the reverse reorg is possible.
+	if _, err := ReorgTo(ctx, a, b, 2, 1); err != nil {
+		t.Fatalf("unable to reorg back: %v", err)
+	}
+
+	// No blocks are mined on a node with a count of zero, so node b just
+	// extends the chain of node a.
+	tip, err = ReorgTo(ctx, a, b, 0, 1)
+	if err != nil {
+		t.Fatalf("unable to extend the chain of node a: %v", err)
+	}
+	if err := a.AssertTip(ctx, tip); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testSendOutputsEstimatedFee(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testSendOutputsEstimatedFee start")
+	defer tracef(t, "testSendOutputsEstimatedFee end")
+
+	addr, err := r.NewAddress(ctx)
+	if err != nil {
+		t.Fatalf("unable to get new address: %v", err)
+	}
+	addrScriptVer, addrScript := addr.PaymentScript()
+	output := newTxOut(1e8, addrScriptVer, addrScript)
+	funded, err := r.SendOutputsEstimatedFee(ctx, []*wire.TxOut{output})
+	if err != nil {
+		t.Fatalf("unable to send outputs: %v", err)
+	}
+
+	// The wallet calculates the fee before adding the change output, so
+	// only require the fee to be roughly the fee rate times the size.
+	minFee := funded.FeeRate * dcrutil.Amount(funded.Tx.SerializeSize()/2)
+	if funded.FeeRate <= 0 || funded.Fee < minFee {
+		t.Fatalf("unexpected fee %v at fee rate %v (min fee %v)",
+			funded.Fee, funded.FeeRate, minFee)
+	}
+	txHash := funded.Tx.TxHash()
+	if err := r.WaitForTxInMempool(ctx, &txHash); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine the transaction to leave the mempool empty.
+	if _, err := r.Node.Generate(ctx, 1); err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+}
+
+func testNewCluster(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testNewCluster start")
+	defer tracef(t, "testNewCluster end")
+
+	cluster, err := NewCluster(ctx, t, chaincfg.RegNetParams(), 3)
+	if err != nil {
+		t.Fatalf("unable to create cluster: %v", err)
+	}
+	for i, from := range cluster.Nodes {
+		if i > 0 && from.nodeNum != cluster.Nodes[i-1].nodeNum+1 {
+			t.Fatalf("node %d: non-sequential node number %d", i,
+				from.nodeNum)
+		}
+		for _, to := range cluster.Nodes {
+			if from == to {
+				continue
+			}
+			connected, err := NodesConnected(ctx, from, to, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !connected {
+				t.Fatalf("node %d is not connected to the mesh", i)
+			}
+		}
+	}
+
+	// Blocks mined on any node reach the whole cluster.
+	hashes, err := cluster.Nodes[2].GenerateBlocks(ctx, 1)
+	if err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+	if err := cluster.WaitForConvergence(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.Nodes[0].AssertTip(ctx, hashes[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// TearDown fails if any goroutines of the cluster outlive it.
+	if err := cluster.TearDown(); err != nil {
+		t.Fatalf("unable to tear down cluster: %v", err)
+	}
+}
+
+func testFetchProfile(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testFetchProfile start")
+	defer tracef(t, "testFetchProfile end")
+
+	if _, err := r.FetchProfile(ctx, "heap"); err == nil {
+		t.Fatal("expected an error without the profiling server")
+	}
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil,
+		WithProfilePort(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if harness.ProfileAddress() == "" {
+		t.Fatal("profile port was not chosen")
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	profile, err := harness.FetchProfile(ctx, "goroutine?debug=1")
+	if err != nil {
+		t.Fatalf("unable to fetch profile: %v", err)
+	}
+	if !bytes.Contains(profile, []byte("goroutine profile")) {
+		t.Fatalf("unexpected profile %q", profile)
+	}
+}
+
+func testRegenerateCert(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testRegenerateCert start")
+	defer tracef(t, "testRegenerateCert end")
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil,
+		WithCertCurve(elliptic.P256()), WithCertValidity(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	oldCert := harness.RPCConfig().Certificates
+	if err := harness.RegenerateCert(ctx); err != nil {
+		t.Fatalf("unable to regenerate cert: %v", err)
+	}
+	if bytes.Equal(oldCert, harness.RPCConfig().Certificates) {
+		t.Fatal("cert was not regenerated")
+	}
+	if _, _, err := harness.BestBlock(ctx); err != nil {
+		t.Fatalf("RPC call failed after cert regeneration: %v", err)
+	}
+}
+
+func testTLSFiles(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testTLSFiles start")
+	defer tracef(t, "testTLSFiles end")
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "rpc.cert")
+	keyFile := filepath.Join(dir, "rpc.key")
+	if err := genCertPair(certFile, keyFile, nil, 0); err != nil {
+		t.Fatalf("unable to generate cert pair: %v", err)
+	}
+	wantCert, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil,
+		WithTLSFiles(certFile, keyFile), WithStrictTLSFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	if harness.TLSCertPath() != certFile || harness.TLSKeyPath() != keyFile {
+		t.Fatalf("unexpected TLS files %s and %s", harness.TLSCertPath(),
+			harness.TLSKeyPath())
+	}
+	if !bytes.Equal(harness.RPCConfig().Certificates, wantCert) {
+		t.Fatal("RPC config does not use the provided cert")
+	}
+	if _, _, err := harness.BestBlock(ctx); err != nil {
+		t.Fatalf("RPC call failed with provided cert: %v", err)
+	}
+	if err := harness.RegenerateCert(ctx); err == nil {
+		t.Fatal("expected an error regenerating a provided cert")
+	}
+}
+
+func testClientTLS(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testClientTLS start")
+	defer tracef(t, "testClientTLS end")
+
+	// A client that does not trust the CA of the node must fail to
+	// connect.
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "rpc.cert")
+	keyFile := filepath.Join(dir, "rpc.key")
+	if err := genCertPair(certFile, keyFile, nil, 0); err != nil {
+		t.Fatalf("unable to generate cert pair: %v", err)
+	}
+	wrongCert, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpcConf := r.RPCConfig()
+	rpcConf.Certificates = wrongCert
+	client, err := rpcclient.New(&rpcConf, nil)
+	if err == nil {
+		client.Shutdown()
+		t.Fatal("connected with the wrong CA")
+	}
+	if !isCertVerifyError(err) {
+		t.Fatalf("unexpected error connecting with the wrong CA: %v", err)
+	}
+
+	// The harness clients must connect to a node with TLS disabled, while
+	// TLS clients must fail to.
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil,
+		WithDisableTLS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	if _, _, err := harness.BestBlock(ctx); err != nil {
+		t.Fatalf("RPC call failed without TLS: %v", err)
+	}
+	rpcConf = harness.RPCConfig()
+	rpcConf.DisableTLS = false
+	client, err = rpcclient.New(&rpcConf, nil)
+	if err == nil {
+		client.Shutdown()
+		t.Fatal("connected with TLS to a node with TLS disabled")
+	}
+}
+
+func testInvalidateBlock(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testInvalidateBlock start")
+	defer tracef(t, "testInvalidateBlock end")
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	hashes, err := harness.GenerateBlocks(ctx, 3)
+	if err != nil {
+		t.Fatalf("unable to generate blocks: %v", err)
+	}
+
+	// Invalidating the second block must make the first one the tip.
+	if err := harness.InvalidateBlock(ctx, hashes[1]); err != nil {
+		t.Fatalf("unable to invalidate block: %v", err)
+	}
+	if err := harness.AssertTip(ctx, hashes[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reconsidering it must restore the original tip.
+	if err := harness.ReconsiderBlock(ctx, hashes[1]); err != nil {
+		t.Fatalf("unable to reconsider block: %v", err)
+	}
+	if err := harness.WaitForBestBlock(ctx, hashes[2]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testGetBlock(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testGetBlock start")
+	defer tracef(t, "testGetBlock end")
+
+	hashes, err := r.GenerateBlocks(ctx, 1)
+	if err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+	block, err := r.GetBlock(ctx, hashes[0])
+	if err != nil {
+		t.Fatalf("unable to get block: %v", err)
+	}
+	if block.BlockHash() != *hashes[0] {
+		t.Fatalf("unexpected block %v, want %v", block.BlockHash(),
+			hashes[0])
+	}
+
+	verbose, err := r.GetBlockVerbose(ctx, hashes[0], false)
+	if err != nil {
+		t.Fatalf("unable to get verbose block: %v", err)
+	}
+	if verbose.Hash != hashes[0].String() ||
+		verbose.Height != int64(block.Header.Height) {
+		t.Fatalf("unexpected verbose block %s at height %d",
+			verbose.Hash, verbose.Height)
+	}
+	if len(verbose.Tx) != len(block.Transactions) || len(verbose.RawTx) != 0 {
+		t.Fatalf("unexpected verbose block txs: %d hashes, %d raw txs",
+			len(verbose.Tx), len(verbose.RawTx))
+	}
+
+	verbose, err = r.GetBlockVerbose(ctx, hashes[0], true)
+	if err != nil {
+		t.Fatalf("unable to get verbose block with txs: %v", err)
+	}
+	if len(verbose.RawTx) != len(block.Transactions) {
+		t.Fatalf("unexpected number of verbose txs %d, want %d",
+			len(verbose.RawTx), len(block.Transactions))
+	}
+}
+
+func testClient(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testClient start")
+	defer tracef(t, "testClient end")
+
+	var client RPCClient = r.Client()
+	hash, height, err := client.GetBestBlock(ctx)
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	wantHash, wantHeight, err := r.BestBlock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *hash != *wantHash || height != wantHeight {
+		t.Fatalf("unexpected best block %v (height %d), want %v "+
+			"(height %d)", hash, height, wantHash, wantHeight)
+	}
+
+	// Methods not covered by the interface are reachable via RawRequest.
+	res, err := client.RawRequest(ctx, "getblockcount", nil)
+	if err != nil {
+		t.Fatalf("unable to issue raw request: %v", err)
+	}
+	var count int64
+	if err := json.Unmarshal(res, &count); err != nil {
+		t.Fatalf("unable to decode block count: %v", err)
+	}
+	if count != height {
+		t.Fatalf("unexpected block count %d, want %d", count, height)
+	}
+}
+
+func testRPCEndpoint(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testRPCEndpoint start")
+	defer tracef(t, "testRPCEndpoint end")
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil,
+		WithRPCEndpoint(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	rpcConf := harness.RPCConfig()
+	if !rpcConf.HTTPPostMode {
+		t.Fatal("RPC config is not in HTTP POST mode")
+	}
+	client, err := rpcclient.New(&rpcConf, nil)
+	if err != nil {
+		t.Fatalf("unable to create HTTP POST client: %v", err)
+	}
+	defer client.Shutdown()
+
+	hashes, err := harness.GenerateBlocks(ctx, 1)
+	if err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+	best, err := client.GetBestBlockHash(ctx)
+	if err != nil {
+		t.Fatalf("HTTP POST call failed: %v", err)
+	}
+	if *best != *hashes[0] {
+		t.Fatalf("unexpected best block %v, want %v", best, hashes[0])
+	}
+}
+
+func testFaucet(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testFaucet start")
+	defer tracef(t, "testFaucet end")
+
+	// The harness starts without mature outputs, so the faucet must mine
+	// them.
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	addr, err := r.NewAddress(ctx)
+	if err != nil {
+		t.Fatalf("unable to generate address: %v", err)
+	}
+	const amount = dcrutil.Amount(5e8)
+	txHash, err := harness.Faucet(ctx, addr, amount)
+	if err != nil {
+		t.Fatalf("unable to fund address: %v", err)
+	}
+	out, err := harness.TxOut(ctx, txHash, 0, false)
+	if err != nil {
+		t.Fatalf("unable to fetch funding output: %v", err)
+	}
+	if out == nil {
+		t.Fatal("funding output is not confirmed")
+	}
+	if out.Value != amount || out.Confirmations != 1 {
+		t.Fatalf("unexpected funding output of %v with %d confirmations",
+			out.Value, out.Confirmations)
+	}
+}
+
+func testWaitForConfirmations(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testWaitForConfirmations start")
+	defer tracef(t, "testWaitForConfirmations end")
+
+	addr, err := r.NewAddress(ctx)
+	if err != nil {
+		t.Fatalf("unable to generate address: %v", err)
+	}
+	txHash, err := r.FundAddress(ctx, addr, dcrutil.Amount(1e8))
+	if err != nil {
+		t.Fatalf("unable to fund address: %v", err)
+	}
+
+	// A pending transaction must time out reporting it is pending.
+	shortCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	_, err = r.WaitForConfirmations(shortCtx, txHash, 1)
+	cancel()
+	if err == nil || !strings.Contains(err.Error(), "still pending") {
+		t.Fatalf("unexpected error for pending tx: %v", err)
+	}
+
+	hashes, err := r.GenerateBlocks(ctx, 2)
+	if err != nil {
+		t.Fatalf("unable to generate blocks: %v", err)
+	}
+	blockHash, err := r.WaitForConfirmations(ctx, txHash, 2)
+	if err != nil {
+		t.Fatalf("unable to wait for confirmations: %v", err)
+	}
+	if *blockHash != *hashes[0] {
+		t.Fatalf("unexpected block %v, want %v", blockHash, hashes[0])
+	}
+
+	// An unknown transaction must time out reporting it was not found.
+	shortCtx, cancel = context.WithTimeout(ctx, 500*time.Millisecond)
+	_, err = r.WaitForConfirmations(shortCtx, &chainhash.Hash{}, 1)
+	cancel()
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("unexpected error for unknown tx: %v", err)
+	}
+}
+
+func testOpenRPCClients(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testOpenRPCClients start")
+	defer tracef(t, "testOpenRPCClients end")
+
+	// The harness client uses one of the websockets.
+	const maxWebsockets = 4
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil,
+		WithRPCMaxWebsockets(maxWebsockets))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	clients, err := harness.OpenRPCClients(ctx, maxWebsockets-1)
+	if err != nil {
+		t.Fatalf("unable to open clients up to the limit: %v", err)
+	}
+	defer func() {
+		for _, client := range clients {
+			client.Shutdown()
+			client.WaitForShutdown()
+		}
+	}()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if extra, err := harness.OpenRPCClients(timeoutCtx, 1); err == nil {
+		extra[0].Shutdown()
+		t.Fatal("opened a client beyond the limit")
+	}
+}
+
+func testWithTestDir(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testWithTestDir start")
+	defer tracef(t, "testWithTestDir end")
+
+	dir := t.TempDir()
+	keepFile := filepath.Join(dir, "keep")
+	if err := os.WriteFile(keepFile, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil,
+		WithTestDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		harness.TearDown()
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	if err := harness.TearDown(); err != nil {
+		t.Fatalf("unable to tear down harness: %v", err)
+	}
+	if debug || trace {
+		if err := harness.Cleanup(); err != nil {
+			t.Fatalf("unable to clean up: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("caller provided test dir was removed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "keep" {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Fatalf("unexpected test dir contents %v", names)
+	}
+}
+
+func testWaitForTxInMempool(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testWaitForTxInMempool start")
+	defer tracef(t, "testWaitForTxInMempool end")
+
+	// A transaction unknown to the node never reaches the mempool.
+	var unknownHash chainhash.Hash
+	unknownHash[0] = 0x01
+	shortCtx, cancel := context.WithTimeout(ctx, time.Millisecond*500)
+	err := r.WaitForTxInMempool(shortCtx, &unknownHash)
+	cancel()
+	if err == nil {
+		t.Fatal("expected an error waiting for an unknown transaction")
+	}
+	if !strings.Contains(err.Error(), unknownHash.String()) {
+		t.Fatalf("error does not include the transaction hash: %v", err)
+	}
+
+	addr, err := r.NewAddress(ctx)
+	if err != nil {
+		t.Fatalf("unable to get new address: %v", err)
+	}
+	addrScriptVer, addrScript := addr.PaymentScript()
+	output := newTxOut(1e8, addrScriptVer, addrScript)
+	txHash, err := r.SendOutputs(ctx, []*wire.TxOut{output}, 10000)
+	if err != nil {
+		t.Fatalf("coinbase spend failed: %v", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	if err := r.WaitForTxInMempool(waitCtx, txHash); err != nil {
+		t.Fatalf("transaction did not reach the mempool: %v", err)
+	}
+
+	// Mine the transaction to leave the mempool empty.
+	if _, err := r.Node.Generate(ctx, 1); err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+}
+
+func testJoinBlocks(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testJoinBlocks start")
+	defer tracef(t, "testJoinBlocks end")
+
+	// Create a second harness with only the genesis block so it is behind
+	// the main harness.
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	nodeSlice := []*Harness{r, harness}
+	blocksSynced := make(chan error)
+	go func() {
+		if err := JoinNodes(ctx, nodeSlice, Blocks); err != nil {
+			blocksSynced <- fmt.Errorf("unable to join node on blocks: %w", err)
+			return
+		}
+		blocksSynced <- nil
+	}()
+
+	// This select case should fall through to the default as the goroutine
+	// should be blocked on the JoinNodes calls.
+	select {
+	case err := <-blocksSynced:
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Fatalf("blocks detected as synced yet local harness is behind")
+	default:
+	}
+
+	// Connect the local harness to the main harness which will sync the
+	// chains.
+	if err := ConnectNode(ctx, harness, r); err != nil {
+		t.Fatalf("unable to connect harnesses: %v", err)
+	}
+
+	// Select once again with a special timeout case after 1 minute. The
+	// goroutine above should now be blocked on sending into the unbuffered
+	// channel. The send should immediately succeed. In order to avoid the
+	// test hanging indefinitely, a 1 minute timeout is in place.
+	select {
+	case err := <-blocksSynced:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Minute):
+		t.Fatalf("blocks never detected as synced")
+	}
+}
+
+func testWaitForConvergence(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testWaitForConvergence start")
+	defer tracef(t, "testWaitForConvergence end")
+
+	// Create a second harness with only the genesis block so its tip
+	// differs from the main harness.
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	// The nodes are not connected, so they should not converge.
+	nodeSlice := []*Harness{r, harness}
+	shortCtx, cancel := context.WithTimeout(ctx, time.Millisecond*500)
+	err = WaitForConvergence(shortCtx, nodeSlice)
+	cancel()
+	if err == nil {
+		t.Fatalf("nodes detected as converged yet local harness is behind")
+	}
+	if !strings.Contains(err.Error(), harness.P2PAddress()) {
+		t.Fatalf("error does not include the tip of each node: %v", err)
+	}
+
+	// Connect the nodes and wait for them to agree on the same tip.
+	if err := ConnectNode(ctx, harness, r); err != nil {
+		t.Fatalf("unable to connect harnesses: %v", err)
+	}
+	if err := WaitForConvergence(ctx, nodeSlice); err != nil {
+		t.Fatalf("nodes did not converge: %v", err)
+	}
+}
+
+func testGuardPanic(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testGuardPanic start")
+	defer tracef(t, "testGuardPanic end")
+
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		_ = harness.TearDown()
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+
+	nodesBefore := ActiveNodeCount()
+	recovered := func() (r interface{}) {
+		defer func() { r = recover() }()
+		harness.GuardPanic(func() {
+			panic("boom")
+		})
+		return nil
+	}()
+	if recovered != "boom" {
+		t.Fatalf("unexpected recovered value: %v", recovered)
+	}
+	if n := ActiveNodeCount(); n != nodesBefore-1 {
+		t.Fatalf("node was not stopped: %d running nodes, want %d", n,
+			nodesBefore-1)
+	}
+}
+
+func testResetChain(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testResetChain start")
+	defer tracef(t, "testResetChain end")
+
+	// Use a fresh harness, since resetting the chain would affect other
+	// tests relying on the main harness.
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, true, 2); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	if err := harness.ResetChain(ctx); err != nil {
+		t.Fatalf("unable to reset chain: %v", err)
+	}
+	_, height, err := harness.Node.GetBestBlock(ctx)
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	if height != 0 {
+		t.Fatalf("unexpected height after reset: got %d, want 0", height)
+	}
+	if balance := harness.ConfirmedBalance(); balance != 0 {
+		t.Fatalf("unexpected balance after reset: %v", balance)
+	}
+
+	// The wallet tracks the blocks mined after the reset.
+	if _, err := harness.Node.Generate(ctx, 1); err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+	if err := harness.waitWalletSynced(ctx); err != nil {
+		t.Fatalf("wallet did not sync: %v", err)
+	}
+}
+
+func testMiningAddresses(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testMiningAddresses start")
+	defer tracef(t, "testMiningAddresses end")
+
+	// Addresses of other networks are rejected.
+	simnetAddr, err := stdaddr.NewAddressPubKeyHashEcdsaSecp256k1V0(
+		make([]byte, 20), chaincfg.SimNetParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = New(t, chaincfg.RegNetParams(), nil, nil,
+		WithMiningAddresses(simnetAddr))
+	if err == nil {
+		t.Fatal("expected an error for a simnet mining address")
+	}
+
+	addr, err := r.NewAddress(ctx)
+	if err != nil {
+		t.Fatalf("unable to get new address: %v", err)
+	}
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil,
+		WithMiningAddresses(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, true, 2); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	// The internal wallet is still funded.
+	if n := harness.wallet.numMatureOutputs(); n < 2 {
+		t.Fatalf("unexpected number of mature outputs: %d", n)
+	}
+
+	// Some of the mined blocks pay to the additional address.
+	_, addrScript := addr.PaymentScript()
+	hashes, err := harness.Node.Generate(ctx, 20)
+	if err != nil {
+		t.Fatalf("unable to generate blocks: %v", err)
+	}
+	for _, hash := range hashes {
+		block, err := harness.Node.GetBlock(ctx, hash)
+		if err != nil {
+			t.Fatalf("unable to get block: %v", err)
+		}
+		for _, out := range block.Transactions[0].TxOut {
+			if bytes.Equal(out.PkScript, addrScript) {
+				return
+			}
+		}
+	}
+	t.Fatalf("no block paid to the additional mining address %v", addr)
+}
+
+func testMineThenRollback(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testMineThenRollback start")
+	defer tracef(t, "testMineThenRollback end")
+
+	// Use a fresh harness, since rolling back the chain would affect other
+	// tests relying on the main harness.
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, true, 2); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	addr, err := harness.NewAddress(ctx)
+	if err != nil {
+		t.Fatalf("unable to get new address: %v", err)
+	}
+	addrScriptVer, addrScript := addr.PaymentScript()
+	output := newTxOut(1e8, addrScriptVer, addrScript)
+	txid, err := harness.SendOutputs(ctx, []*wire.TxOut{output}, 10000)
+	if err != nil {
+		t.Fatalf("coinbase spend failed: %v", err)
+	}
+	if err := harness.Node.RegenTemplate(ctx); err != nil {
+		t.Fatalf("unable to regenerate block template: %v", err)
+	}
+	time.Sleep(time.Millisecond * 500)
+
+	startHash, err := harness.Node.GetBestBlockHash(ctx)
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	if err := harness.MineThenRollback(ctx, txid, 3); err != nil {
+		t.Fatalf("unable to mine then rollback: %v", err)
+	}
+	if err := harness.AssertTip(ctx, startHash); err != nil {
+		t.Fatalf("unexpected tip after rollback: %v", err)
+	}
+}
+
+func testMemWalletReorg(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testMemWalletReorg start")
+	defer tracef(t, "testMemWalletReorg end")
+
+	// Create a fresh harness, we'll be using the main harness to force a
+	// re-org on this local harness.
+	harness, err := New(t, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, true, 5); err != nil {
+		t.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	// Ensure the internal wallet has the expected balance.
+	expectedBalance := dcrutil.Amount(5 * 300 * dcrutil.AtomsPerCoin)
+	walletBalance := harness.ConfirmedBalance()
+	if expectedBalance != walletBalance {
+		t.Fatalf("wallet balance incorrect: expected %v, got %v",
+			expectedBalance, walletBalance)
+	}
+
+	// Now connect this local harness to the main harness then wait for
+	// their chains to synchronize.
+	if err := ConnectNode(ctx, harness, r); err != nil {
+		t.Fatalf("unable to connect harnesses: %v", err)
+	}
+	nodeSlice := []*Harness{r, harness}
+	if err := JoinNodes(ctx, nodeSlice, Blocks); err != nil {
+		t.Fatalf("unable to join node on blocks: %v", err)
+	}
+
+	// The original wallet should now have a balance of 0 Coin as its entire
+	// chain should have been decimated in favor of the main harness'
+	// chain.
+	expectedBalance = dcrutil.Amount(0)
+	walletBalance = harness.ConfirmedBalance()
+	if expectedBalance != walletBalance {
+		t.Fatalf("wallet balance incorrect: expected %v, got %v",
+			expectedBalance, walletBalance)
+	}
+}
+
+func testMemWalletLockedOutputs(ctx context.Context, r *Harness, t *testing.T) {
+	tracef(t, "testMemWalletLockedOutputs start")
+	defer tracef(t, "testMemWalletLockedOutputs end")
+
+	// Obtain the initial balance of the wallet at this point.
+	startingBalance := r.ConfirmedBalance()
+
+	// First, create a signed transaction spending some outputs.
+	addr, err := r.NewAddress(ctx)
 	if err != nil {
 		t.Fatalf("unable to generate new address: %v", err)
 	}
@@ -679,6 +2333,42 @@ func TestHarness(t *testing.T) {
 				f:    testSendOutputs,
 				name: "testSendOutputs",
 			},
+			{
+				f:    testTxOut,
+				name: "testTxOut",
+			},
+			{
+				f:    testBlockchainInfo,
+				name: "testBlockchainInfo",
+			},
+			{
+				f:    testWaitForAgendaStatus,
+				name: "testWaitForAgendaStatus",
+			},
+			{
+				f:    testAssertChainMatches,
+				name: "testAssertChainMatches",
+			},
+			{
+				f:    testGenerateBlockAtTime,
+				name: "testGenerateBlockAtTime",
+			},
+			{
+				f:    testConcurrentGenerateBlocks,
+				name: "testConcurrentGenerateBlocks",
+			},
+			{
+				f:    testGenerateBlocksPastSVH,
+				name: "testGenerateBlocksPastSVH",
+			},
+			{
+				f:    testAssertBlockContainsTxs,
+				name: "testAssertBlockContainsTxs",
+			},
+			{
+				f:    testBlockSubsidy,
+				name: "testBlockSubsidy",
+			},
 			{
 				f:    testConnectNode,
 				name: "testConnectNode",
@@ -695,6 +2385,114 @@ func TestHarness(t *testing.T) {
 				f:    testActiveHarnesses,
 				name: "testActiveHarnesses",
 			},
+			{
+				f:    testWaitForBlockHeight,
+				name: "testWaitForBlockHeight",
+			},
+			{
+				f:    testNotifyBlocks,
+				name: "testNotifyBlocks",
+			},
+			{
+				f:    testPurchaseTickets,
+				name: "testPurchaseTickets",
+			},
+			{
+				f:    testSetUpStakeChain,
+				name: "testSetUpStakeChain",
+			},
+			{
+				f:    testSnapshotDataDir,
+				name: "testSnapshotDataDir",
+			},
+			{
+				f:    testRestart,
+				name: "testRestart",
+			},
+			{
+				f:    testWaitForTxInMempool,
+				name: "testWaitForTxInMempool",
+			},
+			{
+				f:    testWithTestDir,
+				name: "testWithTestDir",
+			},
+			{
+				f:    testOpenRPCClients,
+				name: "testOpenRPCClients",
+			},
+			{
+				f:    testWaitForConfirmations,
+				name: "testWaitForConfirmations",
+			},
+			{
+				f:    testFaucet,
+				name: "testFaucet",
+			},
+			{
+				f:    testRPCEndpoint,
+				name: "testRPCEndpoint",
+			},
+			{
+				f:    testClient,
+				name: "testClient",
+			},
+			{
+				f:    testGetBlock,
+				name: "testGetBlock",
+			},
+			{
+				f:    testInvalidateBlock,
+				name: "testInvalidateBlock",
+			},
+			{
+				f:    testClientTLS,
+				name: "testClientTLS",
+			},
+			{
+				f:    testTLSFiles,
+				name: "testTLSFiles",
+			},
+			{
+				f:    testRegenerateCert,
+				name: "testRegenerateCert",
+			},
+			{
+				f:    testFetchProfile,
+				name: "testFetchProfile",
+			},
+			{
+				f:    testNewCluster,
+				name: "testNewCluster",
+			},
+			{
+				f:    testSendOutputsEstimatedFee,
+				name: "testSendOutputsEstimatedFee",
+			},
+			{
+				f:    testReorgTo,
+				name: "testReorgTo",
+			},
+			{
+				f:    testResourceUsage,
+				name: "testResourceUsage",
+			},
+			{
+				f:    testExistsAddress,
+				name: "testExistsAddress",
+			},
+			{
+				f:    testTxIndexDisabled,
+				name: "testTxIndexDisabled",
+			},
+			{
+				f:    testNotifyNewTransactions,
+				name: "testNotifyNewTransactions",
+			},
+			{
+				f:    testLimitedRPCClient,
+				name: "testLimitedRPCClient",
+			},
 			{
 				f:    testJoinBlocks,
 				name: "testJoinBlocks",
@@ -703,6 +2501,38 @@ func TestHarness(t *testing.T) {
 				f:    testJoinMempools, // Depends on results of testJoinBlocks
 				name: "testJoinMempools",
 			},
+			{
+				f:    testWaitForConvergence,
+				name: "testWaitForConvergence",
+			},
+			{
+				f:    testSetRelay,
+				name: "testSetRelay",
+			},
+			{
+				f:    testSendRawP2PMessage,
+				name: "testSendRawP2PMessage",
+			},
+			{
+				f:    testLocalServices,
+				name: "testLocalServices",
+			},
+			{
+				f:    testMineThenRollback,
+				name: "testMineThenRollback",
+			},
+			{
+				f:    testResetChain,
+				name: "testResetChain",
+			},
+			{
+				f:    testGuardPanic,
+				name: "testGuardPanic",
+			},
+			{
+				f:    testMiningAddresses,
+				name: "testMiningAddresses",
+			},
 			{
 				f:    testMemWalletReorg,
 				name: "testMemWalletReorg",
@@ -735,3 +2565,45 @@ func TestHarness(t *testing.T) {
 
 	testTearDownAll(t)
 }
+
+// BenchmarkResetChain measures the time taken to return a harness to a fresh
+// chain via ResetChain. It is meant to be compared with
+// BenchmarkSetUpTearDown.
+func BenchmarkResetChain(b *testing.B) {
+	ctx := context.Background()
+	harness, err := New(nil, chaincfg.RegNetParams(), nil, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := harness.SetUp(ctx, false, 0); err != nil {
+		_ = harness.TearDown()
+		b.Fatalf("unable to complete harness setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := harness.ResetChain(ctx); err != nil {
+			b.Fatalf("unable to reset chain: %v", err)
+		}
+	}
+}
+
+// BenchmarkSetUpTearDown measures the time taken to obtain a fresh chain by
+// creating, setting up and tearing down a new harness.
+func BenchmarkSetUpTearDown(b *testing.B) {
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		harness, err := New(nil, chaincfg.RegNetParams(), nil, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := harness.SetUp(ctx, false, 0); err != nil {
+			_ = harness.TearDown()
+			b.Fatalf("unable to complete harness setup: %v", err)
+		}
+		if err := harness.TearDown(); err != nil {
+			b.Fatalf("unable to tear down harness: %v", err)
+		}
+	}
+}
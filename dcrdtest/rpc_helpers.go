@@ -0,0 +1,639 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/decred/dcrd/blockchain/standalone/v2"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v3"
+	"github.com/decred/dcrd/dcrjson/v4"
+	"github.com/decred/dcrd/dcrutil/v4"
+	dcrdtypes "github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+	"github.com/decred/dcrd/txscript/v4/stdaddr"
+	"github.com/decred/dcrd/wire"
+)
+
+// MedianTimePast returns the median time past of the current best block of
+// the harness' node, as calculated by dcrd for the purposes of validating
+// transaction lock times.
+func (h *Harness) MedianTimePast(ctx context.Context) (time.Time, error) {
+	bestHash, err := h.Node.GetBestBlockHash(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	header, err := h.Node.GetBlockHeaderVerbose(ctx, bestHash)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(header.MedianTime, 0), nil
+}
+
+// BestBlock returns the hash and height of the best block of the harness'
+// node.
+func (h *Harness) BestBlock(ctx context.Context) (*chainhash.Hash, int64, error) {
+	return h.Node.GetBestBlock(ctx)
+}
+
+// GetBlock returns the block with the passed hash from the harness' node.
+//
+// This and the other typed RPC wrappers of the harness allow tests to avoid
+// depending on the specific version of the RPC client used by dcrdtest.
+func (h *Harness) GetBlock(ctx context.Context, hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	return h.Node.GetBlock(ctx, hash)
+}
+
+// GetBlockVerbose returns the verbose description of the block with the passed
+// hash from the harness' node. When verboseTx is true, the result includes the
+// full description of the transactions of the block instead of their hashes.
+func (h *Harness) GetBlockVerbose(ctx context.Context, hash *chainhash.Hash, verboseTx bool) (*dcrdtypes.GetBlockVerboseResult, error) {
+	return h.Node.GetBlockVerbose(ctx, hash, verboseTx)
+}
+
+// AssertTip returns an error if the best block of the harness' node is not the
+// block with the passed hash. The error includes the actual tip.
+//
+// This is an immediate check. WaitForConvergence may be used to first wait
+// for block relay among multiple nodes to settle.
+func (h *Harness) AssertTip(ctx context.Context, wantHash *chainhash.Hash) error {
+	tip, height, err := h.BestBlock(ctx)
+	if err != nil {
+		return err
+	}
+	if *tip != *wantHash {
+		return fmt.Errorf("unexpected tip: got %v (height %d), want %v",
+			tip, height, wantHash)
+	}
+	return nil
+}
+
+// ExistsAddress returns whether the passed address was ever used on the main
+// chain or in the mempool of the harness' node, as tracked by its exists
+// address index. An error is returned if the index was disabled with
+// WithExistsAddrIndex.
+func (h *Harness) ExistsAddress(ctx context.Context, addr stdaddr.Address) (bool, error) {
+	if h.opts.noExistsAddrIndex {
+		return false, errors.New("exists address index is disabled")
+	}
+	return h.Node.ExistsAddress(ctx, addr)
+}
+
+// blockRPC issues the passed block related RPC, which takes the hash of the
+// block as its only parameter and which is not provided by the RPC client.
+func (h *Harness) blockRPC(ctx context.Context, method string, hash *chainhash.Hash) error {
+	param, err := json.Marshal(hash.String())
+	if err != nil {
+		return err
+	}
+	_, err = h.Node.RawRequest(ctx, method, []json.RawMessage{param})
+	return err
+}
+
+// InvalidateBlock marks the passed block as invalid in the harness' node,
+// causing it and all of its descendants to be removed from the main chain, so
+// that the best valid side chain, if any, becomes the main chain.
+//
+// The call blocks until the block is no longer part of the main chain of the
+// node, as reported by getbestblock and getblockhash. An error is returned if
+// that does not happen by the time the context is done.
+func (h *Harness) InvalidateBlock(ctx context.Context, hash *chainhash.Hash) error {
+	header, err := h.Node.GetBlockHeader(ctx, hash)
+	if err != nil {
+		return err
+	}
+	height := int64(header.Height)
+	if err := h.blockRPC(ctx, "invalidateblock", hash); err != nil {
+		return err
+	}
+
+	return pollUntil(ctx, func() (bool, error) {
+		_, bestHeight, err := h.BestBlock(ctx)
+		if err != nil {
+			return false, err
+		}
+		if bestHeight < height {
+			return true, nil
+		}
+		mainHash, err := h.Node.GetBlockHash(ctx, height)
+		if err != nil {
+			return false, err
+		}
+		return *mainHash != *hash, nil
+	}, func(err error) error {
+		return fmt.Errorf("block %v is still in the main chain after "+
+			"being invalidated: %v", hash, err)
+	})
+}
+
+// ReconsiderBlock removes the invalid status of the passed block, its
+// ancestors and its descendants in the harness' node, undoing InvalidateBlock.
+// The node then switches to the chain with the reconsidered block if it has
+// the most cumulative work, before the call returns.
+//
+// Use WaitForBestBlock to wait for nodes connected to this one to follow.
+func (h *Harness) ReconsiderBlock(ctx context.Context, hash *chainhash.Hash) error {
+	return h.blockRPC(ctx, "reconsiderblock", hash)
+}
+
+// WaitForBestBlock blocks until the best block of the harness' node is the
+// block with the passed hash, as reported by getbestblock.
+//
+// An error including the last observed best block is returned if the node does
+// not switch to the block by the time the context is done.
+func (h *Harness) WaitForBestBlock(ctx context.Context, hash *chainhash.Hash) error {
+	var best *chainhash.Hash
+	var height int64
+	return pollUntil(ctx, func() (bool, error) {
+		var err error
+		best, height, err = h.BestBlock(ctx)
+		if err != nil {
+			return false, err
+		}
+		return *best == *hash, nil
+	}, func(err error) error {
+		return fmt.Errorf("best block is %v (height %d) instead of %v: %v",
+			best, height, hash, err)
+	})
+}
+
+// blockHasTx returns true if the passed block includes the specified
+// transaction in either its regular or stake tree.
+func blockHasTx(block *wire.MsgBlock, txHash *chainhash.Hash) bool {
+	for _, tx := range block.Transactions {
+		if tx.TxHash() == *txHash {
+			return true
+		}
+	}
+	for _, tx := range block.STransactions {
+		if tx.TxHash() == *txHash {
+			return true
+		}
+	}
+	return false
+}
+
+// CoinbaseTx returns the coinbase transaction of the specified block. An
+// error is returned if the block is not known by the harness' node.
+func (h *Harness) CoinbaseTx(ctx context.Context, blockHash *chainhash.Hash) (*wire.MsgTx, error) {
+	block, err := h.GetBlock(ctx, blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch block %v: %w", blockHash,
+			err)
+	}
+	if len(block.Transactions) == 0 {
+		return nil, fmt.Errorf("block %v has no transactions", blockHash)
+	}
+	return block.Transactions[0], nil
+}
+
+// AssertBlockContainsTxs checks that the specified block contains exactly the
+// passed transactions. The coinbase is ignored, as are the transactions of the
+// stake tree which are not in want (such as votes), since those are
+// determined by the node rather than the test. An error listing the missing
+// and unexpected transactions is returned when the block does not match.
+func (h *Harness) AssertBlockContainsTxs(ctx context.Context, blockHash *chainhash.Hash, want []*chainhash.Hash) error {
+	block, err := h.GetBlock(ctx, blockHash)
+	if err != nil {
+		return err
+	}
+
+	wantSet := make(map[chainhash.Hash]struct{}, len(want))
+	for _, hash := range want {
+		wantSet[*hash] = struct{}{}
+	}
+	found := make(map[chainhash.Hash]struct{}, len(want))
+	var unexpected []string
+	for i, tx := range block.Transactions {
+		if i == 0 {
+			// Skip the coinbase.
+			continue
+		}
+		txHash := tx.TxHash()
+		if _, ok := wantSet[txHash]; ok {
+			found[txHash] = struct{}{}
+			continue
+		}
+		unexpected = append(unexpected, txHash.String())
+	}
+	for _, tx := range block.STransactions {
+		txHash := tx.TxHash()
+		if _, ok := wantSet[txHash]; ok {
+			found[txHash] = struct{}{}
+		}
+	}
+	var missing []string
+	for _, hash := range want {
+		if _, ok := found[*hash]; !ok {
+			missing = append(missing, hash.String())
+		}
+	}
+
+	if len(missing) == 0 && len(unexpected) == 0 {
+		return nil
+	}
+	return fmt.Errorf("block %v does not contain the expected "+
+		"transactions: missing %v, unexpected %v", blockHash, missing,
+		unexpected)
+}
+
+// MineThenRollback mines depth blocks on top of the current tip, ensuring
+// the first one of them confirms the specified transaction, which must be in
+// the mempool of the harness' node. It then invalidates the block that
+// confirmed the transaction, rolling the chain back to the tip that existed
+// before the call, and waits until the transaction is returned to the
+// mempool.
+//
+// This is useful to exercise the reconciliation of the mempool on reorgs. An
+// error is returned if the transaction is not mined in the first generated
+// block or if it is not back in the mempool by the time the context is done,
+// for example because it was dropped by the node.
+//
+// Note the invalidated blocks are not reconsidered, so any blocks mined after
+// this call extend the chain from the original tip.
+func (h *Harness) MineThenRollback(ctx context.Context, txHash *chainhash.Hash, depth int) error {
+	if depth < 1 {
+		return fmt.Errorf("depth must be at least 1 (got %d)", depth)
+	}
+
+	prevTip, err := h.Node.GetBestBlockHash(ctx)
+	if err != nil {
+		return err
+	}
+	hashes, err := h.GenerateBlocks(ctx, uint32(depth))
+	if err != nil {
+		return err
+	}
+	block, err := h.GetBlock(ctx, hashes[0])
+	if err != nil {
+		return err
+	}
+	if !blockHasTx(block, txHash) {
+		return fmt.Errorf("transaction %v was not mined in block %v",
+			txHash, hashes[0])
+	}
+
+	if err := h.InvalidateBlock(ctx, hashes[0]); err != nil {
+		return fmt.Errorf("unable to invalidate block %v: %w", hashes[0],
+			err)
+	}
+	tip, err := h.Node.GetBestBlockHash(ctx)
+	if err != nil {
+		return err
+	}
+	if *tip != *prevTip {
+		return fmt.Errorf("tip after rollback is %v instead of %v", tip,
+			prevTip)
+	}
+
+	return pollUntil(ctx, func() (bool, error) {
+		_, found, err := h.mempoolHasTx(ctx, txHash)
+		return found, err
+	}, func(err error) error {
+		return fmt.Errorf("transaction %v was not returned to the mempool "+
+			"after rolling back %d blocks: %v", txHash, depth, err)
+	})
+}
+
+// mempoolHasTx returns the hashes of the transactions in the mempool of the
+// harness' node and whether they include the passed transaction.
+func (h *Harness) mempoolHasTx(ctx context.Context, txHash *chainhash.Hash) ([]*chainhash.Hash, bool, error) {
+	mempool, err := h.Node.GetRawMempool(ctx, dcrdtypes.GRMAll)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, hash := range mempool {
+		if *hash == *txHash {
+			return mempool, true, nil
+		}
+	}
+	return mempool, false, nil
+}
+
+// TxOutResult houses information about an unspent transaction output, as
+// returned by TxOut.
+type TxOutResult struct {
+	// BestBlock is the hash of the best block at the time the output was
+	// queried.
+	BestBlock chainhash.Hash
+
+	// Confirmations is the number of confirmations of the transaction
+	// that created the output. It is zero for outputs of mempool
+	// transactions.
+	Confirmations int64
+
+	// Value is the amount of the output.
+	Value dcrutil.Amount
+
+	// ScriptVersion and PkScript are the version and the public key
+	// script of the output.
+	ScriptVersion uint16
+	PkScript      []byte
+
+	// Coinbase is true when the output was created by a coinbase
+	// transaction.
+	Coinbase bool
+}
+
+// TxOut returns information about the specified unspent transaction output
+// by using the gettxout RPC. Outputs of transactions in both the regular and
+// stake transaction trees are found. When includeMempool is true, outputs
+// created by transactions in the mempool are also considered.
+//
+// A nil result (and no error) is returned when the output does not exist or
+// has already been spent.
+func (h *Harness) TxOut(ctx context.Context, hash *chainhash.Hash, index uint32, includeMempool bool) (*TxOutResult, error) {
+	for _, tree := range []int8{wire.TxTreeRegular, wire.TxTreeStake} {
+		res, err := h.Node.GetTxOut(ctx, hash, index, tree, includeMempool)
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			continue
+		}
+
+		bestBlock, err := chainhash.NewHashFromStr(res.BestBlock)
+		if err != nil {
+			return nil, err
+		}
+		value, err := dcrutil.NewAmount(res.Value)
+		if err != nil {
+			return nil, err
+		}
+		pkScript, err := hex.DecodeString(res.ScriptPubKey.Hex)
+		if err != nil {
+			return nil, err
+		}
+		return &TxOutResult{
+			BestBlock:     *bestBlock,
+			Confirmations: res.Confirmations,
+			Value:         value,
+			ScriptVersion: res.ScriptPubKey.Version,
+			PkScript:      pkScript,
+			Coinbase:      res.Coinbase,
+		}, nil
+	}
+	return nil, nil
+}
+
+// DeploymentInfo houses the state of a consensus rule change agenda, as
+// returned by BlockchainInfo.
+type DeploymentInfo struct {
+	// Status is the status of the agenda (e.g. "defined", "started",
+	// "lockedin", "active" or "failed").
+	Status string
+
+	// Since is the height of the first block of the current status.
+	Since int64
+
+	// StartTime and ExpireTime define the period during which the agenda
+	// may be voted on.
+	StartTime  time.Time
+	ExpireTime time.Time
+}
+
+// BlockchainInfo houses information about the state of the chain of a node,
+// as returned by BlockchainInfo.
+type BlockchainInfo struct {
+	// Chain is the name of the network of the node.
+	Chain string
+
+	// Blocks and Headers are the heights of the best validated block and
+	// of the best known header.
+	Blocks  int64
+	Headers int64
+
+	// SyncHeight is the height the node is syncing to.
+	SyncHeight int64
+
+	// BestBlockHash is the hash of the best validated block.
+	BestBlockHash chainhash.Hash
+
+	// Difficulty is the compact representation of the current proof of
+	// work target difficulty and DifficultyRatio is the ratio of that
+	// difficulty to the minimum difficulty of the network.
+	Difficulty      uint32
+	DifficultyRatio float64
+
+	// VerificationProgress is an estimate of the fraction of the chain
+	// that has been validated.
+	VerificationProgress float64
+
+	// ChainWork is the total cumulative work in the best chain.
+	ChainWork *big.Int
+
+	// InitialBlockDownload is true while the node is performing the
+	// initial chain download.
+	InitialBlockDownload bool
+
+	// MaxBlockSize is the maximum size of blocks at the current height.
+	MaxBlockSize int64
+
+	// Deployments is the state of the consensus rule change agendas,
+	// indexed by agenda ID.
+	Deployments map[string]DeploymentInfo
+}
+
+// BlockchainInfo returns information about the state of the chain of the
+// harness' node by using the getblockchaininfo RPC.
+func (h *Harness) BlockchainInfo(ctx context.Context) (*BlockchainInfo, error) {
+	res, err := h.Node.GetBlockChainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bestBlockHash, err := chainhash.NewHashFromStr(res.BestBlockHash)
+	if err != nil {
+		return nil, err
+	}
+	chainWork, ok := new(big.Int).SetString(res.ChainWork, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid chain work %q", res.ChainWork)
+	}
+	deployments := make(map[string]DeploymentInfo, len(res.Deployments))
+	for id, agenda := range res.Deployments {
+		deployments[id] = DeploymentInfo{
+			Status:     agenda.Status,
+			Since:      agenda.Since,
+			StartTime:  time.Unix(int64(agenda.StartTime), 0),
+			ExpireTime: time.Unix(int64(agenda.ExpireTime), 0),
+		}
+	}
+
+	return &BlockchainInfo{
+		Chain:                res.Chain,
+		Blocks:               res.Blocks,
+		Headers:              res.Headers,
+		SyncHeight:           res.SyncHeight,
+		BestBlockHash:        *bestBlockHash,
+		Difficulty:           res.Difficulty,
+		DifficultyRatio:      res.DifficultyRatio,
+		VerificationProgress: res.VerificationProgress,
+		ChainWork:            chainWork,
+		InitialBlockDownload: res.InitialBlockDownload,
+		MaxBlockSize:         res.MaxBlockSize,
+		Deployments:          deployments,
+	}, nil
+}
+
+// agendaActive returns true if the agenda with the passed ID is active in the
+// passed blockchain info.
+func agendaActive(info *BlockchainInfo, agendaID string) bool {
+	deployment, ok := info.Deployments[agendaID]
+	return ok && deployment.Status == "active"
+}
+
+// BlockSubsidy returns the proof of work, total stake vote and treasury
+// subsidies of a block at the specified height in the harness network,
+// assuming the block includes the maximum number of votes. The values are
+// calculated with the consensus subsidy calculator for the harness chain
+// params, so they can be used to assert the actual outputs of coinbases and
+// votes.
+//
+// Whether the subsidy split change (DCP0010) and treasury agendas are active
+// is determined from their status at the current tip of the node.
+func (h *Harness) BlockSubsidy(ctx context.Context, height int64) (work, stake, treasury dcrutil.Amount, err error) {
+	info, err := h.BlockchainInfo(ctx)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	isDCP0010Active := agendaActive(info, chaincfg.VoteIDChangeSubsidySplit)
+	isTreasuryEnabled := agendaActive(info, chaincfg.VoteIDTreasury)
+
+	cache := standalone.NewSubsidyCache(h.ActiveNet)
+	voters := h.ActiveNet.TicketsPerBlock
+	work = dcrutil.Amount(cache.CalcWorkSubsidyV2(height, voters,
+		isDCP0010Active))
+	stake = dcrutil.Amount(cache.CalcStakeVoteSubsidyV2(height,
+		isDCP0010Active) * int64(voters))
+	treasury = dcrutil.Amount(cache.CalcTreasurySubsidy(height, voters,
+		isTreasuryEnabled))
+	return work, stake, treasury, nil
+}
+
+// WaitForAgendaStatus blocks until the consensus rule change agenda with the
+// passed deployment ID reaches the specified status (e.g. "started",
+// "lockedin" or "active") in the harness' node, as reported by
+// getblockchaininfo. This does not mine any blocks, so callers are expected
+// to advance the chain (for example, in another goroutine) while waiting.
+//
+// An error including the last observed status is returned if the agenda does
+// not reach the status by the time the context is done.
+func (h *Harness) WaitForAgendaStatus(ctx context.Context, deploymentID, status string) error {
+	current := "unknown agenda"
+	return pollUntil(ctx, func() (bool, error) {
+		info, err := h.BlockchainInfo(ctx)
+		if err != nil {
+			return false, err
+		}
+		deployment, ok := info.Deployments[deploymentID]
+		if ok {
+			current = deployment.Status
+		}
+		return ok && deployment.Status == status, nil
+	}, func(err error) error {
+		return fmt.Errorf("agenda %s did not reach status %q (current "+
+			"status: %s): %v", deploymentID, status, current, err)
+	})
+}
+
+// WaitForBlockHeight blocks until the main chain of the harness' node reaches
+// at least the passed height, as reported by getblockcount.
+//
+// An error including the last observed height is returned if the node does
+// not reach the height by the time the context is done.
+func (h *Harness) WaitForBlockHeight(ctx context.Context, height int64) error {
+	var current int64
+	return pollUntil(ctx, func() (bool, error) {
+		var err error
+		current, err = h.Node.GetBlockCount(ctx)
+		return current >= height, err
+	}, func(err error) error {
+		return fmt.Errorf("node did not reach height %d (current "+
+			"height: %d): %v", height, current, err)
+	})
+}
+
+// WaitForTxInMempool blocks until the transaction with the passed hash is in
+// the mempool of the harness' node, as reported by getrawmempool. This is
+// useful to ensure a transaction sent to another node was relayed before
+// mining it.
+//
+// An error including the current contents of the mempool is returned if the
+// transaction does not reach the mempool by the time the context is done.
+func (h *Harness) WaitForTxInMempool(ctx context.Context, txHash *chainhash.Hash) error {
+	var mempool []*chainhash.Hash
+	return pollUntil(ctx, func() (bool, error) {
+		var found bool
+		var err error
+		mempool, found, err = h.mempoolHasTx(ctx, txHash)
+		return found, err
+	}, func(err error) error {
+		return fmt.Errorf("tx %v did not reach the mempool (mempool: %v): "+
+			"%v", txHash, mempool, err)
+	})
+}
+
+// WaitForConfirmations blocks until the transaction with the passed hash has at
+// least confs confirmations on the main chain of the harness' node, as reported
+// by getrawtransaction, and returns the hash of the block that mined it. This
+// only polls the node, so the blocks must be mined by the test, by other nodes
+// or by the CPU miner enabled with WithGenerateThreads.
+//
+// An error is returned right away if the transaction, after being seen in the
+// mempool, is dropped from it without being mined, for example due to being
+// double spent. Otherwise, an error stating whether the transaction is still
+// pending or was never seen by the node is returned if it does not reach the
+// confirmations by the time the context is done.
+//
+// This requires the transaction index of the node, which is enabled unless
+// disabled with WithTxIndex.
+func (h *Harness) WaitForConfirmations(ctx context.Context, txHash *chainhash.Hash, confs int64) (*chainhash.Hash, error) {
+	if confs < 1 {
+		return nil, fmt.Errorf("confirmations must be at least 1 (got %d)",
+			confs)
+	}
+	if h.opts.noTxIndex {
+		return nil, errors.New("waiting for confirmations requires the " +
+			"transaction index")
+	}
+
+	var seen bool
+	var confirmations int64
+	var blockHash string
+	err := pollUntil(ctx, func() (bool, error) {
+		tx, err := h.Node.GetRawTransactionVerbose(ctx, txHash)
+		var rpcErr *dcrjson.RPCError
+		switch {
+		case errors.As(err, &rpcErr) && rpcErr.Code == dcrjson.ErrRPCNoTxInfo:
+			if seen {
+				return false, fmt.Errorf("tx %v was dropped from the "+
+					"mempool without being mined", txHash)
+			}
+			return false, nil
+		case err != nil:
+			return false, err
+		}
+		seen = true
+		confirmations, blockHash = tx.Confirmations, tx.BlockHash
+		return confirmations >= confs, nil
+	}, func(err error) error {
+		if !seen {
+			return fmt.Errorf("tx %v was not found: %v", txHash, err)
+		}
+		return fmt.Errorf("tx %v is still pending with %d of %d "+
+			"confirmations: %v", txHash, confirmations, confs, err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(blockHash)
+}
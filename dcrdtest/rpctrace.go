@@ -0,0 +1,432 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// redactedMethods are the RPC methods whose params carry credentials and
+// therefore must never be written to the trace.
+var redactedMethods = map[string]struct{}{
+	"authenticate": {},
+}
+
+// traceRequest is the subset of a JSON-RPC request relevant for tracing.
+type traceRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	ID     interface{}     `json:"id"`
+}
+
+// traceResponse is the subset of a JSON-RPC response or notification relevant
+// for tracing.
+type traceResponse struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+	ID     interface{}     `json:"id"`
+}
+
+// pendingCall tracks an in-flight RPC request so that its response can be
+// matched to the originating method.
+type pendingCall struct {
+	id     interface{}
+	method string
+	start  time.Time
+}
+
+// rpcTracer is an in-process TLS proxy that sits between the RPC clients
+// created by the harness and the RPC server of the dcrd node. Every request
+// and response that flows through it is written to the configured writer, if
+// any, and calls that take longer than the slow call threshold are reported.
+//
+// Both the websocket and the HTTP POST transports are supported.
+type rpcTracer struct {
+	w    io.Writer
+	wMtx sync.Mutex
+
+	// target is the address of the RPC server of the dcrd node.
+	target string
+
+	// rootCAs is the pool used to verify the certificate of the dcrd node
+	// when connecting to it.
+	rootCAs *x509.CertPool
+
+	listener net.Listener
+	server   *http.Server
+	wg       sync.WaitGroup
+
+	pendingMtx sync.Mutex
+	pending    map[string]pendingCall
+
+	// slowThreshold is the duration above which calls are reported via
+	// warnf. When zero, slow calls are not reported.
+	slowThreshold time.Duration
+	warnf         func(format string, args ...interface{})
+}
+
+// newRPCTracer creates a new RPC tracing proxy that forwards requests to the
+// RPC server at target, writing the trace of the calls to w. The proxy serves
+// TLS connections using the passed cert and key files, which should be the
+// same ones used by the dcrd node so that clients may use the same
+// certificates to connect to either one. w may be nil, in which case the calls
+// are not traced.
+func newRPCTracer(w io.Writer, target string, certificates []byte, certFile, keyFile string) (*rpcTracer, error) {
+	keyPair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(certificates) {
+		return nil, errors.New("unable to parse RPC certificates")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	tracer := &rpcTracer{
+		w:        w,
+		target:   target,
+		rootCAs:  rootCAs,
+		listener: tls.NewListener(listener, tlsConfig),
+		pending:  make(map[string]pendingCall),
+	}
+	tracer.server = &http.Server{
+		Handler:           tracer,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return tracer, nil
+}
+
+// addr returns the address where the tracer is accepting connections.
+func (tr *rpcTracer) addr() string {
+	return tr.listener.Addr().String()
+}
+
+// start starts serving connections.
+func (tr *rpcTracer) start() {
+	tr.wg.Add(1)
+	go func() {
+		defer tr.wg.Done()
+		tr.server.Serve(tr.listener)
+	}()
+}
+
+// stop closes the listener and all active connections and waits for the
+// serving goroutine to finish.
+func (tr *rpcTracer) stop() error {
+	err := tr.server.Close()
+	tr.wg.Wait()
+	return err
+}
+
+// logf writes a single trace line to the tracer's writer.
+//
+// This function is safe for concurrent access.
+func (tr *rpcTracer) logf(format string, args ...interface{}) {
+	if tr.w == nil {
+		return
+	}
+
+	tr.wMtx.Lock()
+	defer tr.wMtx.Unlock()
+
+	ts := time.Now().Format("15:04:05.000")
+	fmt.Fprintf(tr.w, ts+" "+format+"\n", args...)
+}
+
+// pendingKey returns the key used to track a pending call with the given id
+// for the passed connection.
+func pendingKey(connID string, id interface{}) string {
+	return fmt.Sprintf("%s/%v", connID, id)
+}
+
+// dropPending forgets the pending calls of the passed connection, which is
+// closed, so they never receive a response. Each dropped call is traced.
+//
+// This function is safe for concurrent access.
+func (tr *rpcTracer) dropPending(connID string) {
+	prefix := pendingKey(connID, "")
+	var dropped []pendingCall
+	tr.pendingMtx.Lock()
+	for key, call := range tr.pending {
+		if strings.HasPrefix(key, prefix) {
+			dropped = append(dropped, call)
+			delete(tr.pending, key)
+		}
+	}
+	tr.pendingMtx.Unlock()
+
+	for _, call := range dropped {
+		tr.logf("%s <- id=%v method=%s dropped without a response (%v)",
+			connID, call.id, call.method, time.Since(call.start))
+	}
+}
+
+// decodeBatch returns the messages of the passed JSON-RPC batch, which is a
+// JSON array of requests or responses. False is returned when data is not a
+// batch.
+func decodeBatch(data []byte) ([]json.RawMessage, bool) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '[' {
+		return nil, false
+	}
+	var batch []json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, false
+	}
+	return batch, true
+}
+
+// traceRequest decodes and traces a request, or each request of a batch, sent
+// by a client.
+func (tr *rpcTracer) traceRequest(connID string, data []byte) {
+	batch, ok := decodeBatch(data)
+	if !ok {
+		tr.traceRequestMsg(connID, data)
+		return
+	}
+	tr.logf("%s -> batch of %d requests", connID, len(batch))
+	for _, msg := range batch {
+		tr.traceRequestMsg(connID, msg)
+	}
+}
+
+// traceRequestMsg decodes and traces a single request sent by a client.
+func (tr *rpcTracer) traceRequestMsg(connID string, data []byte) {
+	var req traceRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		tr.logf("%s -> malformed request: %v", connID, err)
+		return
+	}
+
+	params := string(req.Params)
+	if _, ok := redactedMethods[req.Method]; ok {
+		params = "[redacted]"
+	}
+
+	tr.pendingMtx.Lock()
+	tr.pending[pendingKey(connID, req.ID)] = pendingCall{
+		id:     req.ID,
+		method: req.Method,
+		start:  time.Now(),
+	}
+	tr.pendingMtx.Unlock()
+
+	tr.logf("%s -> id=%v method=%s params=%s", connID, req.ID, req.Method,
+		params)
+}
+
+// traceResponse decodes and traces a response or notification, or each
+// response of a batch, sent by the RPC server.
+func (tr *rpcTracer) traceResponse(connID string, data []byte) {
+	batch, ok := decodeBatch(data)
+	if !ok {
+		tr.traceResponseMsg(connID, data)
+		return
+	}
+	tr.logf("%s <- batch of %d responses", connID, len(batch))
+	for _, msg := range batch {
+		tr.traceResponseMsg(connID, msg)
+	}
+}
+
+// traceResponseMsg decodes and traces a single response or notification sent
+// by the RPC server.
+func (tr *rpcTracer) traceResponseMsg(connID string, data []byte) {
+	var resp traceResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		tr.logf("%s <- malformed response: %v", connID, err)
+		return
+	}
+
+	// Notifications do not have an id.
+	if resp.ID == nil && resp.Method != "" {
+		tr.logf("%s <- notification method=%s params=%s", connID,
+			resp.Method, resp.Params)
+		return
+	}
+
+	key := pendingKey(connID, resp.ID)
+	tr.pendingMtx.Lock()
+	call, ok := tr.pending[key]
+	delete(tr.pending, key)
+	tr.pendingMtx.Unlock()
+
+	var elapsed time.Duration
+	if ok {
+		elapsed = time.Since(call.start)
+		if tr.slowThreshold > 0 && elapsed > tr.slowThreshold {
+			tr.warnf("Slow RPC call: method=%s took %v (threshold %v)",
+				call.method, elapsed, tr.slowThreshold)
+		}
+	}
+	if len(resp.Error) > 0 && !bytes.Equal(resp.Error, []byte("null")) {
+		tr.logf("%s <- id=%v method=%s error=%s (%v)", connID, resp.ID,
+			call.method, resp.Error, elapsed)
+		return
+	}
+	tr.logf("%s <- id=%v method=%s result=%s (%v)", connID, resp.ID,
+		call.method, resp.Result, elapsed)
+}
+
+// ServeHTTP proxies the passed request to the dcrd node. It is part of the
+// http.Handler interface.
+func (tr *rpcTracer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	connID := r.RemoteAddr
+	if websocket.IsWebSocketUpgrade(r) {
+		tr.proxyWebsocket(connID, w, r)
+		return
+	}
+	tr.proxyHTTPPost(connID, w, r)
+}
+
+// proxyHeader returns the subset of the request headers that must be
+// forwarded to the dcrd node.
+func proxyHeader(r *http.Request) http.Header {
+	header := make(http.Header)
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		header.Set("Authorization", auth)
+	}
+	return header
+}
+
+// proxyWebsocket proxies a websocket connection to the dcrd node, tracing
+// every message exchanged.
+func (tr *rpcTracer) proxyWebsocket(connID string, w http.ResponseWriter, r *http.Request) {
+	dialer := websocket.Dialer{
+		TLSClientConfig: &tls.Config{
+			RootCAs:    tr.rootCAs,
+			MinVersion: tls.VersionTLS12,
+		},
+		HandshakeTimeout: 10 * time.Second,
+	}
+	url := "wss://" + tr.target + r.URL.Path
+	serverConn, resp, err := dialer.Dial(url, proxyHeader(r))
+	if err != nil {
+		tr.logf("%s unable to dial %s: %v", connID, url, err)
+		status := http.StatusBadGateway
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	defer serverConn.Close()
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(*http.Request) bool { return true },
+	}
+	clientConn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		tr.logf("%s unable to upgrade connection: %v", connID, err)
+		return
+	}
+	defer clientConn.Close()
+	tr.logf("%s connected (websocket)", connID)
+	defer tr.logf("%s disconnected", connID)
+	defer tr.dropPending(connID)
+
+	// pump copies messages from src to dst until either side is closed,
+	// passing each copied message to trace.
+	pump := func(dst, src *websocket.Conn, trace func(string, []byte)) {
+		for {
+			msgType, data, err := src.ReadMessage()
+			if err != nil {
+				dst.Close()
+				return
+			}
+			trace(connID, data)
+			if err := dst.WriteMessage(msgType, data); err != nil {
+				src.Close()
+				return
+			}
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pump(serverConn, clientConn, tr.traceRequest)
+		close(done)
+	}()
+	pump(clientConn, serverConn, tr.traceResponse)
+	<-done
+}
+
+// proxyHTTPPost proxies a single HTTP POST JSON-RPC request to the dcrd node,
+// tracing both the request and its response.
+func (tr *rpcTracer) proxyHTTPPost(connID string, w http.ResponseWriter, r *http.Request) {
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tr.traceRequest(connID, reqBody)
+	defer tr.dropPending(connID)
+
+	url := "https://" + tr.target + r.URL.Path
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, url,
+		bytes.NewReader(reqBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req.Header = proxyHeader(r)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:    tr.rootCAs,
+				MinVersion: tls.VersionTLS12,
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+	resp, err := client.Do(req)
+	if err != nil {
+		tr.logf("%s unable to forward request: %v", connID, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		tr.logf("%s unable to read response: %v", connID, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if resp.StatusCode == http.StatusOK {
+		tr.traceResponse(connID, respBody)
+	} else {
+		tr.logf("%s <- status=%d %s", connID, resp.StatusCode,
+			bytes.TrimSpace(respBody))
+	}
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	w.Write(respBody)
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRPCTracerTrace ensures the RPC tracer correctly matches responses to
+// their requests and never writes credentials to the trace.
+func TestRPCTracerTrace(t *testing.T) {
+	var buf bytes.Buffer
+	tr := &rpcTracer{
+		w:       &buf,
+		pending: make(map[string]pendingCall),
+	}
+
+	const connID = "127.0.0.1:1234"
+	tr.traceRequest(connID, []byte(`{"jsonrpc":"1.0","method":"authenticate",`+
+		`"params":["user","secretpass"],"id":1}`))
+	tr.traceResponse(connID, []byte(`{"result":null,"error":null,"id":1}`))
+	tr.traceRequest(connID, []byte(`{"jsonrpc":"1.0","method":"getblockcount",`+
+		`"params":[],"id":2}`))
+	tr.traceResponse(connID, []byte(`{"result":null,"error":{"code":-1,`+
+		`"message":"boom"},"id":2}`))
+	tr.traceResponse(connID, []byte(`{"jsonrpc":"1.0","method":"blockconnected",`+
+		`"params":["00"],"id":null}`))
+
+	trace := buf.String()
+	if strings.Contains(trace, "secretpass") {
+		t.Fatalf("trace contains credentials:\n%s", trace)
+	}
+
+	wantLines := []string{
+		"-> id=1 method=authenticate params=[redacted]",
+		"<- id=1 method=authenticate result=null",
+		"-> id=2 method=getblockcount params=[]",
+		`<- id=2 method=getblockcount error={"code":-1,"message":"boom"}`,
+		`<- notification method=blockconnected params=["00"]`,
+	}
+	lines := strings.Split(strings.TrimSpace(trace), "\n")
+	if len(lines) != len(wantLines) {
+		t.Fatalf("unexpected number of trace lines: got %d, want %d\n%s",
+			len(lines), len(wantLines), trace)
+	}
+	for i, want := range wantLines {
+		if !strings.Contains(lines[i], want) {
+			t.Fatalf("trace line %d: got %q, want it to contain %q", i,
+				lines[i], want)
+		}
+	}
+
+	if len(tr.pending) != 0 {
+		t.Fatalf("unexpected pending calls: %v", tr.pending)
+	}
+}
+
+// TestRPCTracerSlowCalls ensures the RPC tracer reports the calls that take
+// longer than the slow call threshold, even when tracing is disabled.
+func TestRPCTracerSlowCalls(t *testing.T) {
+	var warnings []string
+	tr := &rpcTracer{
+		pending:       make(map[string]pendingCall),
+		slowThreshold: time.Minute,
+		warnf: func(format string, args ...interface{}) {
+			warnings = append(warnings, fmt.Sprintf(format, args...))
+		},
+	}
+
+	const connID = "127.0.0.1:1234"
+	tr.traceRequest(connID, []byte(`{"jsonrpc":"1.0","method":"getblockcount",`+
+		`"params":[],"id":1}`))
+	tr.traceRequest(connID, []byte(`{"jsonrpc":"1.0","method":"getbestblock",`+
+		`"params":[],"id":2}`))
+
+	// Simulate the second call taking longer than the threshold.
+	key := pendingKey(connID, float64(2))
+	call := tr.pending[key]
+	call.start = call.start.Add(-2 * time.Minute)
+	tr.pending[key] = call
+
+	tr.traceResponse(connID, []byte(`{"result":1,"error":null,"id":1}`))
+	tr.traceResponse(connID, []byte(`{"result":null,"error":null,"id":2}`))
+
+	if len(warnings) != 1 {
+		t.Fatalf("unexpected number of warnings: got %d, want 1: %q",
+			len(warnings), warnings)
+	}
+	if !strings.Contains(warnings[0], "method=getbestblock") {
+		t.Fatalf("unexpected warning: %q", warnings[0])
+	}
+}
+
+// TestRPCTracerBatch ensures the RPC tracer traces each call of JSON-RPC
+// batches.
+func TestRPCTracerBatch(t *testing.T) {
+	var buf bytes.Buffer
+	tr := &rpcTracer{
+		w:       &buf,
+		pending: make(map[string]pendingCall),
+	}
+
+	const connID = "127.0.0.1:1234"
+	tr.traceRequest(connID, []byte(`[{"jsonrpc":"1.0","method":"getblockcount",`+
+		`"params":[],"id":1},{"jsonrpc":"1.0","method":"getbestblockhash",`+
+		`"params":[],"id":2}]`))
+	tr.traceResponse(connID, []byte(`[{"result":10,"error":null,"id":1},`+
+		`{"result":"00","error":null,"id":2}]`))
+
+	trace := buf.String()
+	wantLines := []string{
+		"-> batch of 2 requests",
+		"-> id=1 method=getblockcount params=[]",
+		"-> id=2 method=getbestblockhash params=[]",
+		"<- batch of 2 responses",
+		"<- id=1 method=getblockcount result=10",
+		`<- id=2 method=getbestblockhash result="00"`,
+	}
+	lines := strings.Split(strings.TrimSpace(trace), "\n")
+	if len(lines) != len(wantLines) {
+		t.Fatalf("unexpected number of trace lines: got %d, want %d\n%s",
+			len(lines), len(wantLines), trace)
+	}
+	for i, want := range wantLines {
+		if !strings.Contains(lines[i], want) {
+			t.Fatalf("trace line %d: got %q, want it to contain %q", i,
+				lines[i], want)
+		}
+	}
+	if len(tr.pending) != 0 {
+		t.Fatalf("unexpected pending calls: %v", tr.pending)
+	}
+}
+
+// TestRPCTracerDropPending ensures the pending calls of a closed connection
+// are forgotten without affecting the calls of other connections.
+func TestRPCTracerDropPending(t *testing.T) {
+	var buf bytes.Buffer
+	tr := &rpcTracer{
+		w:       &buf,
+		pending: make(map[string]pendingCall),
+	}
+
+	const connID, otherConnID = "127.0.0.1:1234", "127.0.0.1:12345"
+	for _, id := range []string{connID, otherConnID} {
+		tr.traceRequest(id, []byte(`{"jsonrpc":"1.0",`+
+			`"method":"getblockcount","params":[],"id":1}`))
+	}
+	tr.dropPending(connID)
+
+	if _, ok := tr.pending[pendingKey(connID, float64(1))]; ok {
+		t.Fatal("pending call of the closed connection was not dropped")
+	}
+	if _, ok := tr.pending[pendingKey(otherConnID, float64(1))]; !ok {
+		t.Fatal("pending call of another connection was dropped")
+	}
+	want := connID + " <- id=1 method=getblockcount dropped"
+	if !strings.Contains(buf.String(), want) {
+		t.Fatalf("trace does not contain %q:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/decred/dcrd/chaincfg/v3"
+)
+
+// knownNetDirs are the names of the subdirectories dcrd creates in its data
+// directory for each network.
+var knownNetDirs = []string{
+	chaincfg.MainNetParams().Name,
+	chaincfg.TestNet3Params().Name,
+	chaincfg.SimNetParams().Name,
+	chaincfg.RegNetParams().Name,
+}
+
+// checkSeedDataDir ensures the passed seed data dir contains the data of the
+// specified network.
+func checkSeedDataDir(src string, net *chaincfg.Params) error {
+	if _, err := os.Stat(filepath.Join(src, net.Name)); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, name := range knownNetDirs {
+		if _, err := os.Stat(filepath.Join(src, name)); err == nil {
+			return fmt.Errorf("seed data dir %s contains data for "+
+				"network %s instead of %s", src, name, net.Name)
+		}
+	}
+	return fmt.Errorf("seed data dir %s does not contain data for network %s",
+		src, net.Name)
+}
+
+// copyDir recursively copies the contents of the src dir into the dst dir,
+// creating it if needed.
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0700)
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+// copyFile copies the src file to dst, which is created with the passed
+// permissions.
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// seedDataDir copies the data of the seed data dir src into the data dir of
+// a node after ensuring it is compatible with the node's network.
+func seedDataDir(src, dataDir string, net *chaincfg.Params) error {
+	if err := checkSeedDataDir(src, net); err != nil {
+		return err
+	}
+	return copyDir(src, dataDir)
+}
+
+// SnapshotDataDir copies the data dir of the harness' node into the dst dir,
+// which may then be passed to WithSeedDataDir so that another harness starts
+// already synced to the chain of this one.
+//
+// In order to ensure the databases are consistent, the node is stopped during
+// the copy and started again afterwards, preserving its data. As a result,
+// the mempool of the node is emptied and any P2P connections to it are
+// dropped.
+//
+// NOTE: This method should not be called concurrently with other methods of
+// the harness.
+func (h *Harness) SnapshotDataDir(ctx context.Context, dst string) error {
+	if err := h.waitWalletSynced(ctx); err != nil {
+		return err
+	}
+	if err := h.stopNode(); err != nil {
+		return err
+	}
+	copyErr := copyDir(h.node.config.dataDir, dst)
+	if err := h.startNode(ctx); err != nil {
+		return err
+	}
+	if copyErr != nil {
+		return fmt.Errorf("unable to copy data dir: %w", copyErr)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg/v3"
+)
+
+// TestSeedDataDir ensures seed data dirs are only accepted for their own
+// network and that their contents are fully copied.
+func TestSeedDataDir(t *testing.T) {
+	src := t.TempDir()
+	dbFile := filepath.Join("simnet", "blocks_ffldb", "000001.ldb")
+	if err := os.MkdirAll(filepath.Join(src, filepath.Dir(dbFile)), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, dbFile), []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "data")
+	if err := seedDataDir(src, dst, chaincfg.RegNetParams()); err == nil {
+		t.Fatal("expected an error seeding a regnet data dir")
+	}
+	if err := seedDataDir(src, dst, chaincfg.SimNetParams()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, dbFile))
+	if err != nil {
+		t.Fatalf("seeded file not found: %v", err)
+	}
+	if string(data) != "data" {
+		t.Fatalf("unexpected seeded file contents: %q", data)
+	}
+}
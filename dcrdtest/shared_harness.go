@@ -0,0 +1,108 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/decred/dcrd/chaincfg/v3"
+	dcrdtypes "github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+)
+
+const (
+	// sharedHarnessMatureOutputs is the number of mature coinbase outputs
+	// available in the chain of the shared harness once it is first set
+	// up.
+	sharedHarnessMatureOutputs = 25
+)
+
+var (
+	// sharedHarness is the harness returned by SharedHarness.
+	sharedHarness    *Harness
+	sharedHarnessMtx sync.Mutex
+)
+
+// SharedHarness returns a simnet harness that is shared among all callers in
+// the current process. The harness is lazily created and set up on the first
+// call, with a test chain that provides a number of mature coinbase outputs
+// to its internal wallet.
+//
+// Every subsequent call resets the mempool of the shared harness via
+// ResetMempool before returning it, but the chain itself is preserved. This
+// amortizes the cost of starting a node and building a test chain across
+// many tests, at the cost of tests not being fully isolated from each other:
+// blocks mined and coins spent by one test are observed by the following
+// ones. When the mempool is not empty, the reset restarts the node, which
+// drops its peer connections, so tests must not rely on peers connected by
+// previous tests. Tests that require a pristine chain should use New instead.
+//
+// The shared harness is not bound to any individual test, so its output is
+// written to the standard logger. It is tracked as any other active harness,
+// therefore it is torn down by TearDownAll, which should be called once all
+// tests are done (for example, in TestMain). A new shared harness is created
+// by the next call after that.
+//
+// This function is safe for concurrent access, but callers that use the
+// shared harness concurrently must coordinate among themselves.
+func SharedHarness(ctx context.Context) (*Harness, error) {
+	sharedHarnessMtx.Lock()
+	defer sharedHarnessMtx.Unlock()
+
+	if sharedHarness != nil {
+		harnessStateMtx.RLock()
+		_, active := testInstances[sharedHarness.testNodeDir]
+		harnessStateMtx.RUnlock()
+
+		if active {
+			if err := sharedHarness.ResetMempool(ctx); err != nil {
+				return nil, err
+			}
+			return sharedHarness, nil
+		}
+		sharedHarness = nil
+	}
+
+	h, err := New(nil, chaincfg.SimNetParams(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.SetUp(ctx, true, sharedHarnessMatureOutputs); err != nil {
+		// The harness still needs to be torn down to clean up its
+		// resources. The error is ignored since this is already an
+		// error path.
+		_ = h.TearDown()
+		return nil, err
+	}
+	sharedHarness = h
+	return h, nil
+}
+
+// ResetMempool removes every transaction from the mempool of the harness
+// node, while preserving its chain. Since dcrd does not provide a way of
+// directly clearing its mempool, this is done by restarting the dcrd process,
+// which does not persist its mempool across restarts. The restart drops the
+// peer connections of the node. It is skipped when the mempool is already
+// empty.
+//
+// Any outputs locked in the internal wallet by transactions created via
+// CreateTransaction are unlocked, since those transactions are no longer in
+// the mempool.
+//
+// NOTE: This method should not be called concurrently with other methods of
+// the harness.
+func (h *Harness) ResetMempool(ctx context.Context) error {
+	mempool, err := h.Node.GetRawMempool(ctx, dcrdtypes.GRMAll)
+	if err != nil {
+		return err
+	}
+	if len(mempool) > 0 {
+		if err := h.Restart(ctx); err != nil {
+			return err
+		}
+	}
+	h.wallet.unlockAllOutputs()
+	return nil
+}
@@ -7,12 +7,17 @@ package dcrdtest
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/decred/dcrd/chaincfg/chainhash"
 	dcrdtypes "github.com/decred/dcrd/rpc/jsonrpc/types/v4"
 	"github.com/decred/dcrd/rpcclient/v8"
 )
@@ -111,70 +116,56 @@ func syncBlocks(ctx context.Context, nodes []*Harness) error {
 // harness and the "to" harness.  The connection made is flagged as persistent,
 // therefore in the case of disconnects, "from" will attempt to reestablish a
 // connection to the "to" harness.
+//
+// This function blocks until the "to" harness is listed among the peers of the
+// "from" harness. An error is returned if that does not happen by the time the
+// context is done.
 func ConnectNode(ctx context.Context, from *Harness, to *Harness) error {
 	tracef(from.t, "ConnectNode start")
 	defer tracef(from.t, "ConnectNode end")
 
-	peerInfo, err := from.Node.GetPeerInfo(ctx)
-	if err != nil {
-		return err
-	}
-	numPeers := len(peerInfo)
-	tracef(from.t, "ConnectNode numPeers: %v", numPeers)
-
-	targetAddr := to.node.config.listen
+	targetAddr := to.P2PAddress()
 	if err := from.Node.AddNode(ctx, targetAddr, rpcclient.ANAdd); err != nil {
 		return err
 	}
 	tracef(from.t, "ConnectNode targetAddr: %v", targetAddr)
 
-	// Block until a new connection has been established.
-	peerInfo, err = from.Node.GetPeerInfo(ctx)
-	if err != nil {
-		return err
-	}
-	tracef(from.t, "ConnectNode peerInfo: %v", peerInfo)
-	for len(peerInfo) <= numPeers {
-		peerInfo, err = from.Node.GetPeerInfo(ctx)
-		if err != nil {
-			return err
-		}
-	}
-	tracef(from.t, "ConnectNode len(peerInfo): %v", len(peerInfo))
-
-	return nil
+	// Block until the connection has been established.
+	return waitForPeer(ctx, from, to, true)
 }
 
 // RemoveNode removes the peer-to-peer connection between the "from" harness and
 // the "to" harness. The connection is only removed in this direction, therefore
 // if the reverse connection exists, the nodes may still be connected.
 //
-// This function returns an error if the nodes were not previously connected.
+// This function returns an error if the nodes were not previously connected or
+// if the connection is not dropped by the time the context is done.
 func RemoveNode(ctx context.Context, from *Harness, to *Harness) error {
-	targetAddr := to.node.config.listen
+	targetAddr := to.P2PAddress()
 	if err := from.Node.AddNode(ctx, targetAddr, rpcclient.ANRemove); err != nil {
 		// AddNode(..., ANRemove) returns an error if the peer is not found
 		return err
 	}
 
 	// Block until this particular connection has been dropped.
-	for {
-		peerInfo, err := from.Node.GetPeerInfo(ctx)
-		if err != nil {
-			return err
-		}
-		for _, p := range peerInfo {
-			if p.Addr == targetAddr {
-				// Nodes still connected. Skip and re-fetch the list of nodes.
-				continue
-			}
-		}
-
-		// If this point is reached, then the nodes are not connected anymore.
-		break
-	}
+	return waitForPeer(ctx, from, to, false)
+}
 
-	return nil
+// waitForPeer blocks until the "to" harness is (when connected is true) or is
+// not (when connected is false) listed among the peers of the "from" harness,
+// or until the context is done.
+func waitForPeer(ctx context.Context, from, to *Harness, connected bool) error {
+	return pollUntil(ctx, func() (bool, error) {
+		isConnected, err := NodesConnected(ctx, from, to, false)
+		return isConnected == connected, err
+	}, func(err error) error {
+		state := "connected to"
+		if !connected {
+			state = "disconnected from"
+		}
+		return fmt.Errorf("node %s did not get %s peer %s: %w",
+			from.P2PAddress(), state, to.P2PAddress(), err)
+	})
 }
 
 // NodesConnected verifies whether there is a connection via the p2p interface
@@ -186,7 +177,7 @@ func NodesConnected(ctx context.Context, from, to *Harness, allowReverse bool) (
 		return false, err
 	}
 
-	targetAddr := to.node.config.listen
+	targetAddr := to.P2PAddress()
 	for _, p := range peerInfo {
 		if p.Addr == targetAddr {
 			return true, nil
@@ -203,7 +194,7 @@ func NodesConnected(ctx context.Context, from, to *Harness, allowReverse bool) (
 		return false, err
 	}
 
-	targetAddr = from.node.config.listen
+	targetAddr = from.P2PAddress()
 	for _, p := range peerInfo {
 		if p.Addr == targetAddr {
 			return true, nil
@@ -245,6 +236,16 @@ func ActiveHarnesses() []*Harness {
 	return activeNodes
 }
 
+// ActiveNodeCount returns the number of dcrd processes launched by harnesses
+// of this package that are currently running, that is, which have been
+// started by SetUp but not yet stopped by TearDown. Test suites may check it
+// is zero once they are done to ensure no node processes were leaked.
+//
+// This function is safe for concurrent access.
+func ActiveNodeCount() int {
+	return int(atomic.LoadInt32(&runningNodes))
+}
+
 // PanicAll tears down all active test harnesses.
 // XXX We ignore the mutex because it is *hopefully* locked when this is
 // called.
@@ -267,3 +268,169 @@ func PanicAll(t *testing.T) {
 		time.Sleep(2 * time.Second)
 	}
 }
+
+// WaitForConvergence blocks until all passed nodes report the same best block
+// hash or the passed context is done. Unlike JoinNodes with the Blocks join
+// type, this ensures the nodes agree on the actual tip and not only on its
+// height, which makes it suitable to check that block gossip has settled
+// across a set of connected nodes.
+//
+// If the context is done before the nodes converge, or querying the tip of a
+// node fails (for example, because the context expired during the call), the
+// returned error includes the last observed tip of each node, keyed by its P2P
+// address.
+func WaitForConvergence(ctx context.Context, nodes []*Harness) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	tips := make(map[string]*chainhash.Hash, len(nodes))
+	converged := func() (bool, error) {
+		converged := true
+		for _, node := range nodes {
+			tip, err := node.Node.GetBestBlockHash(ctx)
+			if err != nil {
+				return false, fmt.Errorf("unable to query the tip "+
+					"of node %s: %w (tips: %v)", node.P2PAddress(),
+					err, formatTips(tips))
+			}
+			tips[node.P2PAddress()] = tip
+			if *tip != *tips[nodes[0].P2PAddress()] {
+				converged = false
+			}
+		}
+		return converged, nil
+	}
+	return pollUntil(ctx, converged, func(err error) error {
+		return fmt.Errorf("nodes did not converge to the same tip: %v "+
+			"(tips: %v)", err, formatTips(tips))
+	})
+}
+
+// reorgRestoreTimeout is the max time ReorgTo takes to restore the connections
+// between the nodes when returning an error.
+const reorgRestoreTimeout = 30 * time.Second
+
+// ReorgTo forces a chain reorganization between the two passed connected
+// harnesses. The nodes are disconnected, aBlocks and bBlocks blocks are mined
+// on top of the current tip of a and b respectively, and the nodes are then
+// reconnected, which causes the node with the shorter chain to reorganize to
+// the longer one.
+//
+// The number of blocks must differ, so that there is a single winning chain.
+// Either of them may be zero, in which case no blocks are mined on that node,
+// so its current chain is simply extended by the other one. The nodes must not
+// be connected through any other node, otherwise the chains do not diverge.
+//
+// This function blocks until both nodes converge on the tip of the longer
+// chain, which is returned. The removed connections are restored even when
+// an error is returned, so the nodes are never left disconnected.
+func ReorgTo(ctx context.Context, a, b *Harness, aBlocks, bBlocks uint32) (*chainhash.Hash, error) {
+	if aBlocks == bBlocks {
+		return nil, fmt.Errorf("the number of blocks of each chain "+
+			"must differ (both are %d)", aBlocks)
+	}
+
+	// Disconnect the nodes in every direction they are connected, so that
+	// the connections may be restored afterwards. The connections which
+	// are not restored by the time of returning, due to an error, are
+	// restored with a separate context, since the passed one may be done.
+	conns, err := disconnectNodes(ctx, a, b)
+	defer func() {
+		if len(conns) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(),
+			reorgRestoreTimeout)
+		defer cancel()
+		if _, err := restoreConns(ctx, conns); err != nil {
+			logf(a.t, "ReorgTo: %v", err)
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+	if len(conns) == 0 {
+		return nil, fmt.Errorf("node %s is not connected to node %s",
+			a.P2PAddress(), b.P2PAddress())
+	}
+
+	// Mining is skipped for a count of zero, which dcrd's generate RPC
+	// rejects.
+	var aHashes, bHashes []*chainhash.Hash
+	if aBlocks > 0 {
+		aHashes, err = a.GenerateBlocks(ctx, aBlocks)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if bBlocks > 0 {
+		bHashes, err = b.GenerateBlocks(ctx, bBlocks)
+		if err != nil {
+			return nil, err
+		}
+	}
+	winner := aHashes
+	if bBlocks > aBlocks {
+		winner = bHashes
+	}
+	tip := winner[len(winner)-1]
+
+	conns, err = restoreConns(ctx, conns)
+	if err != nil {
+		return nil, err
+	}
+	if err := WaitForConvergence(ctx, []*Harness{a, b}); err != nil {
+		return nil, err
+	}
+	for _, h := range []*Harness{a, b} {
+		if err := h.AssertTip(ctx, tip); err != nil {
+			return nil, fmt.Errorf("node %s: %w", h.P2PAddress(), err)
+		}
+	}
+	return tip, nil
+}
+
+// pollInterval is the interval between the checks of pollUntil.
+const pollInterval = time.Millisecond * 100
+
+// pollUntil calls cond right away and then every pollInterval until it
+// returns true, in which case nil is returned, or an error, which is returned
+// as is. If the context is done first, the error returned by timeoutErr for
+// the error of the context is returned instead, which allows callers to
+// describe the state they were waiting for.
+func pollUntil(ctx context.Context, cond func() (bool, error), timeoutErr func(ctxErr error) error) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		done, err := cond()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return timeoutErr(ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// formatTips returns a stable, human readable representation of a map of node
+// addresses to their respective best block hashes.
+func formatTips(tips map[string]*chainhash.Hash) string {
+	addrs := make([]string, 0, len(tips))
+	for addr := range tips {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	parts := make([]string, len(addrs))
+	for i, addr := range addrs {
+		parts[i] = fmt.Sprintf("%s=%s", addr, tips[addr])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestPollUntil ensures pollUntil returns once the condition is met, returns
+// the errors of the condition as is and builds the timeout error when the
+// context is done first.
+func TestPollUntil(t *testing.T) {
+	ctx := context.Background()
+
+	calls := 0
+	err := pollUntil(ctx, func() (bool, error) {
+		calls++
+		return calls == 3, nil
+	}, func(err error) error {
+		t.Fatal("unexpected timeout")
+		return err
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("unexpected result: calls %d, err %v", calls, err)
+	}
+
+	errCond := errors.New("condition error")
+	err = pollUntil(ctx, func() (bool, error) {
+		return false, errCond
+	}, func(err error) error {
+		t.Fatal("unexpected timeout")
+		return err
+	})
+	if !errors.Is(err, errCond) {
+		t.Fatalf("unexpected error %v, want %v", err, errCond)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*pollInterval)
+	defer cancel()
+	errTimeout := errors.New("timeout")
+	start := time.Now()
+	err = pollUntil(ctx, func() (bool, error) {
+		return false, nil
+	}, func(err error) error {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("unexpected context error %v", err)
+		}
+		return errTimeout
+	})
+	if !errors.Is(err, errTimeout) {
+		t.Fatalf("unexpected error %v, want %v", err, errTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("pollUntil took %v to time out", elapsed)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/decred/dcrd/txscript/v4/stdaddr"
+	"github.com/decred/dcrd/wire"
+)
+
+// harnessManagedFlags are the dcrd flags that are always set by the harness
+// and which must not be overridden via extra args, otherwise the harness is
+// unable to connect to or clean up after the node.
+var harnessManagedFlags = []string{
+	"--rpcuser",
+	"--rpcpass",
+	"--listen",
+	"--rpclisten",
+	"--rpccert",
+	"--rpckey",
+	"--datadir",
+}
+
+// networkFlags are the dcrd flags that select the network.
+var networkFlags = []string{"--testnet", "--simnet", "--regnet"}
+
+// multiError is an error that groups several independent errors.
+type multiError []error
+
+// Error returns the messages of all grouped errors, one per line.
+func (errs multiError) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the grouped errors.
+func (errs multiError) Unwrap() []error {
+	return errs
+}
+
+// splitFlag splits a command line argument in the form --flag=value into its
+// flag and value.
+func splitFlag(arg string) (string, string) {
+	flag, value, _ := strings.Cut(arg, "=")
+	return flag, value
+}
+
+// Validate checks the configuration of the harness for consistency without
+// launching the dcrd node. It returns an error describing every problem found,
+// so that they may be fixed all at once.
+//
+// The following are checked:
+//   - The extra args do not select a network other than the one of the
+//     harness chain params
+//   - The extra args do not override flags managed by the harness
+//   - The extra args do not drop the exists address index while it is
+//     enabled
+//   - The config file entries do not select a network other than the one
+//     of the harness chain params
+//   - The P2P and RPC listening addresses are valid and distinct
+//   - The ban duration and threshold are not set with banning disabled
+//   - TLS is only disabled with a localhost RPC listening address and
+//     without RPC tracing
+//   - All mining addresses are valid for the harness network
+//   - The max block size is within the limits of the harness network
+//   - The limited RPC credentials differ from the admin ones
+//   - The TLS cert and key files form a valid pair
+//   - The dcrd executable exists
+//
+// Validate is automatically called by SetUp before launching the node.
+func (h *Harness) Validate() error {
+	var errs multiError
+	cfg := h.node.config
+
+	wantNetFlag := map[wire.CurrencyNet]string{
+		wire.TestNet3: "--testnet",
+		wire.SimNet:   "--simnet",
+		wire.RegNet:   "--regnet",
+	}[h.ActiveNet.Net]
+	for _, arg := range cfg.extra {
+		flag, value := splitFlag(arg)
+		for _, netFlag := range networkFlags {
+			if flag == netFlag && flag != wantNetFlag {
+				errs = append(errs, fmt.Errorf("extra arg %q "+
+					"conflicts with network %s", flag,
+					h.ActiveNet.Name))
+			}
+		}
+		for _, managed := range harnessManagedFlags {
+			if flag == managed {
+				errs = append(errs, fmt.Errorf("extra arg %q "+
+					"overrides a flag managed by the harness",
+					flag))
+			}
+		}
+		if flag == "--dropexistsaddrindex" && !cfg.noExistsAddrIndex {
+			errs = append(errs, fmt.Errorf("extra arg %q requires "+
+				"the exists address index to be disabled", flag))
+		}
+		if flag == "--miningaddr" {
+			_, err := stdaddr.DecodeAddress(value, h.ActiveNet)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid mining "+
+					"address %q for network %s: %v", value,
+					h.ActiveNet.Name, err))
+			}
+		}
+	}
+
+	for key := range cfg.configEntries {
+		for _, netFlag := range networkFlags {
+			if "--"+key == netFlag && netFlag != wantNetFlag {
+				errs = append(errs, fmt.Errorf("config file entry "+
+					"%q conflicts with network %s", key,
+					h.ActiveNet.Name))
+			}
+		}
+	}
+
+	for _, addr := range []string{cfg.listen, cfg.rpcListen} {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Errorf("invalid listening address "+
+				"%q: %v", addr, err))
+		}
+	}
+	if cfg.listen == cfg.rpcListen {
+		errs = append(errs, fmt.Errorf("P2P and RPC listening addresses "+
+			"are both %s", cfg.listen))
+	}
+
+	if cfg.noBanning && cfg.banDuration != 0 {
+		errs = append(errs, errors.New("ban duration may not be set "+
+			"with banning disabled"))
+	}
+	if cfg.noBanning && cfg.banThreshold != 0 {
+		errs = append(errs, errors.New("ban threshold may not be set "+
+			"with banning disabled"))
+	}
+
+	if cfg.disableTLS {
+		host, _, err := net.SplitHostPort(cfg.rpcListen)
+		if err == nil && host != "localhost" && host != "127.0.0.1" &&
+			host != "::1" {
+			errs = append(errs, fmt.Errorf("TLS may not be disabled "+
+				"with the non localhost RPC listening address %s",
+				cfg.rpcListen))
+		}
+		if h.opts.rpcTraceWriter != nil || h.opts.slowRPCThreshold > 0 {
+			errs = append(errs, errors.New("TLS may not be disabled "+
+				"with RPC tracing"))
+		}
+	}
+
+	if cfg.rpcLimitUser != "" && cfg.rpcLimitUser == cfg.rpcUser {
+		errs = append(errs, fmt.Errorf("limited RPC user %q is the "+
+			"same as the admin RPC user", cfg.rpcLimitUser))
+	}
+	if cfg.rpcLimitPass != "" && cfg.rpcLimitPass == cfg.rpcPass {
+		errs = append(errs, errors.New("limited RPC password is the "+
+			"same as the admin RPC password"))
+	}
+
+	if cfg.blockMaxSize != 0 {
+		maxSize := uint32(h.ActiveNet.MaximumBlockSizes[0]) - 1000
+		if cfg.blockMaxSize > maxSize {
+			errs = append(errs, fmt.Errorf("max block size %d is "+
+				"above the limit of %d for network %s",
+				cfg.blockMaxSize, maxSize, h.ActiveNet.Name))
+		}
+	}
+
+	if _, err := tls.LoadX509KeyPair(cfg.certFile, cfg.keyFile); err != nil {
+		errs = append(errs, fmt.Errorf("invalid TLS cert pair: %v", err))
+	}
+
+	if _, err := exec.LookPath(cfg.pathToDCRD); err != nil {
+		errs = append(errs, fmt.Errorf("dcrd executable not found: %v",
+			err))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2022 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dcrdtest
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/v3"
+)
+
+// TestHarnessValidate ensures Validate reports every inconsistency of the
+// harness config at once.
+func TestHarnessValidate(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "rpc.cert")
+	keyFile := filepath.Join(dir, "rpc.key")
+	if err := genCertPair(certFile, keyFile, nil, 0); err != nil {
+		t.Fatalf("unable to generate cert pair: %v", err)
+	}
+
+	newHarness := func(extra ...string) *Harness {
+		return &Harness{
+			ActiveNet: chaincfg.SimNetParams(),
+			node: &node{config: &nodeConfig{
+				listen:     "127.0.0.1:18555",
+				rpcListen:  "127.0.0.1:18556",
+				certFile:   certFile,
+				keyFile:    keyFile,
+				pathToDCRD: "go",
+				extra:      extra,
+			}},
+		}
+	}
+
+	// A consistent config does not produce errors.
+	h := newHarness("--simnet", "--debuglevel=debug")
+	if err := h.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	h = newHarness("--testnet", "--rpcuser=other", "--simnet",
+		"--miningaddr=DsUZxxoHJSty8DCfwfartwTYbuhmVct7tJu",
+		"--dropexistsaddrindex")
+	h.node.config.listen = "0.0.0.0:18555"
+	h.node.config.rpcListen = h.node.config.listen
+	h.node.config.noBanning = true
+	h.node.config.banDuration = time.Hour
+	h.node.config.banThreshold = 50
+	h.node.config.disableTLS = true
+	h.opts.slowRPCThreshold = time.Second
+	h.node.config.blockMaxSize = 10000000
+	h.node.config.configEntries = map[string]string{"testnet": "1"}
+	h.node.config.rpcUser = "user"
+	h.node.config.rpcLimitUser = "user"
+	h.node.config.keyFile = filepath.Join(dir, "missing.key")
+	h.node.config.pathToDCRD = filepath.Join(dir, "missing-dcrd")
+	err := h.Validate()
+	var errs multiError
+	if !errors.As(err, &errs) {
+		t.Fatalf("unexpected error type %T: %v", err, err)
+	}
+	wantErrs := []string{
+		`"--testnet" conflicts with network`,
+		`"--rpcuser" overrides a flag managed by the harness`,
+		"invalid mining address",
+		`"--dropexistsaddrindex" requires the exists address index`,
+		`config file entry "testnet" conflicts with network`,
+		"listening addresses are both",
+		"ban duration may not be set with banning disabled",
+		"ban threshold may not be set with banning disabled",
+		"TLS may not be disabled with the non localhost",
+		"TLS may not be disabled with RPC tracing",
+		`limited RPC user "user" is the same as the admin`,
+		"max block size 10000000 is above the limit",
+		"invalid TLS cert pair",
+		"dcrd executable not found",
+	}
+	if len(errs) != len(wantErrs) {
+		t.Fatalf("unexpected number of errors: got %d, want %d\n%v",
+			len(errs), len(wantErrs), err)
+	}
+	for i, want := range wantErrs {
+		if !strings.Contains(errs[i].Error(), want) {
+			t.Fatalf("error %d: got %q, want it to contain %q", i,
+				errs[i], want)
+		}
+	}
+}
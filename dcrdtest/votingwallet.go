@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/decred/dcrd/blockchain/stake/v5"
@@ -135,6 +136,11 @@ type VotingWallet struct {
 
 	// Limit the total number of votes to that.
 	limitNbVotes int
+
+	// winnerWaiters are the channels of the WaitForWinningTicket calls
+	// waiting for a ticket of the wallet to be selected.
+	winnerWaitersMtx sync.Mutex
+	winnerWaiters    []chan *chainhash.Hash
 }
 
 // NewVotingWallet creates a new minimal voting wallet for the given harness.
@@ -203,7 +209,7 @@ func NewVotingWallet(ctx context.Context, hn *Harness) (*VotingWallet, error) {
 		OnWinningTickets: w.onWinningTickets,
 	}
 
-	rpcConf := hn.RPCConfig()
+	rpcConf := hn.wsRPCConfig()
 	for i := 0; i < 20; i++ {
 		if w.c, err = rpcclient.New(&rpcConf, handlers); err != nil {
 			time.Sleep(time.Duration(i) * 50 * time.Millisecond)
@@ -518,6 +524,13 @@ func (w *VotingWallet) handleWinningTicketsNtfn(ctx context.Context, ntfn *winni
 		myTicket bool
 	)
 
+	for _, wt := range ntfn.winningTickets {
+		if _, myTicket = w.tickets[*wt]; myTicket {
+			w.notifyWinningTicket(wt)
+			break
+		}
+	}
+
 	for _, wt := range ntfn.winningTickets {
 		if ticket, myTicket = w.tickets[*wt]; !myTicket {
 			continue
@@ -606,6 +619,51 @@ func (w *VotingWallet) handleWinningTicketsNtfn(ctx context.Context, ntfn *winni
 	w.maturingVotes[maturingHeight] = newUtxos
 }
 
+// notifyWinningTicket delivers the passed winning ticket of the wallet to all
+// pending WaitForWinningTicket calls.
+func (w *VotingWallet) notifyWinningTicket(ticket *chainhash.Hash) {
+	w.winnerWaitersMtx.Lock()
+	waiters := w.winnerWaiters
+	w.winnerWaiters = nil
+	w.winnerWaitersMtx.Unlock()
+
+	for _, c := range waiters {
+		c <- ticket
+	}
+}
+
+// WaitForWinningTicket blocks until one of the tickets purchased by the
+// wallet is selected to vote and returns its hash. If more than one of the
+// tickets is selected in the same block, only the first one is returned.
+//
+// Only selections notified after the call are considered and the wallet must
+// have been started, since the winning tickets are tracked by its
+// notification handler. Note that the wallet automatically votes with the
+// selected tickets.
+func (w *VotingWallet) WaitForWinningTicket(ctx context.Context) (*chainhash.Hash, error) {
+	c := make(chan *chainhash.Hash, 1)
+	w.winnerWaitersMtx.Lock()
+	w.winnerWaiters = append(w.winnerWaiters, c)
+	w.winnerWaitersMtx.Unlock()
+
+	select {
+	case ticket := <-c:
+		return ticket, nil
+	case <-ctx.Done():
+		w.winnerWaitersMtx.Lock()
+		for i, waiter := range w.winnerWaiters {
+			if waiter == c {
+				w.winnerWaiters = append(w.winnerWaiters[:i],
+					w.winnerWaiters[i+1:]...)
+				break
+			}
+		}
+		w.winnerWaitersMtx.Unlock()
+		return nil, fmt.Errorf("no ticket of the wallet was selected "+
+			"to vote: %w", ctx.Err())
+	}
+}
+
 // handleNotifications handles all notifications. This blocks until the passed
 // context is cancelled and MUST be run on a separate goroutine.
 func (w *VotingWallet) handleNotifications(ctx context.Context) {
@@ -640,3 +698,73 @@ func ticketPurchaseStartHeight(net *chaincfg.Params) int64 {
 func requiredTicketCount(net *chaincfg.Params) int {
 	return int((net.CoinbaseMaturity + net.TicketMaturity + 2) * net.TicketsPerBlock)
 }
+
+// SetUpStakeChain advances the chain of the harness past its stake validation
+// height (SVH), creating and starting a voting wallet which purchases tickets
+// and votes on the blocks as required. It then keeps mining blocks until the
+// internal wallet of the harness has at least numMatureOutputs mature
+// outputs.
+//
+// The returned voting wallet is already started. Blocks mined past SVH must
+// be mined with its GenerateBlocks method, so that they receive votes.
+//
+// This must be called after SetUp, before the chain reaches the height at
+// which the voting wallet needs to start purchasing tickets.
+func (h *Harness) SetUpStakeChain(ctx context.Context, numMatureOutputs uint32) (*VotingWallet, error) {
+	_, height, err := h.Node.GetBestBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	purchaseHeight := ticketPurchaseStartHeight(h.ActiveNet)
+	if height >= purchaseHeight {
+		return nil, fmt.Errorf("chain height %d is already past the "+
+			"ticket purchase start height %d", height, purchaseHeight)
+	}
+
+	// The voting wallet is funded by the internal wallet, which requires at
+	// least one mature coinbase output.
+	fundHeight := int64(h.ActiveNet.CoinbaseMaturity) + 2
+	if height < fundHeight {
+		_, err := h.GenerateBlocks(ctx, uint32(fundHeight-height))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := h.waitWalletSynced(ctx); err != nil {
+		return nil, err
+	}
+
+	vw, err := NewVotingWallet(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	if err := vw.Start(ctx); err != nil {
+		return nil, err
+	}
+
+	// Mine past SVH.
+	_, height, err = h.Node.GetBestBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	svh := h.ActiveNet.StakeValidationHeight
+	if _, err := vw.GenerateBlocks(ctx, uint32(svh-height+1)); err != nil {
+		return nil, fmt.Errorf("unable to mine past SVH: %w", err)
+	}
+
+	// Mine until the internal wallet has the requested number of mature
+	// outputs.
+	for {
+		if err := h.waitWalletSynced(ctx); err != nil {
+			return nil, err
+		}
+		if h.wallet.numMatureOutputs() >= int(numMatureOutputs) {
+			break
+		}
+		if _, err := vw.GenerateBlocks(ctx, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	return vw, nil
+}
@@ -6,6 +6,7 @@ package dcrdtest
 
 import (
 	"context"
+	"errors"
 	"os"
 	"testing"
 
@@ -23,6 +24,17 @@ func testCanPassSVH(ctx context.Context, t *testing.T, vw *VotingWallet) {
 		t.Fatalf("unable to obtain best block: %v", err)
 	}
 
+	// Wait for a ticket of the wallet to be selected while blocks are
+	// generated.
+	winnerChan := make(chan error, 1)
+	go func() {
+		ticket, err := vw.WaitForWinningTicket(ctx)
+		if err == nil && ticket == nil {
+			err = errors.New("nil winning ticket")
+		}
+		winnerChan <- err
+	}()
+
 	// Generate enough blocks to get us past SVH.
 	targetHeight := vw.hn.ActiveNet.StakeValidationHeight * 2
 	if targetHeight < startHeight {
@@ -49,6 +61,11 @@ func testCanPassSVH(ctx context.Context, t *testing.T, vw *VotingWallet) {
 	}
 
 	t.Logf("Generated up to block %d\n", targetHeight)
+
+	// Tickets of the wallet must have been selected to vote past SVH.
+	if err := <-winnerChan; err != nil {
+		t.Fatalf("unable to wait for winning ticket: %v", err)
+	}
 }
 
 func TestMinimalVotingWallet(t *testing.T) {